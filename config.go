@@ -32,6 +32,18 @@ var (
 type ConfigHTTP struct {
 	Address             string
 	TimeLoggerThreshold int64 //单位：纳秒
+	ShutdownTimeout     int   //单位：秒
+
+	CertFile      string // 与KeyFile同时设置时使用TLS
+	KeyFile       string
+	ClientCAFile  string   // 设置时要求并验证客户端证书
+	MinTLSVersion string   // 1.0、1.1、1.2、1.3，默认1.2
+	CipherSuites  []string // 如TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256，为空时使用默认值
+	DisableHTTP2  bool     // 使用TLS时默认启用HTTP/2
+
+	ReadTimeout  int //单位：秒
+	WriteTimeout int //单位：秒
+	IdleTimeout  int //单位：秒
 }
 
 // Check --
@@ -39,23 +51,57 @@ func (config ConfigHTTP) Check() bool {
 	if config.Address == "" || !strings.Contains(config.Address, ":") {
 		return false
 	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return false
+	}
+	if config.ClientCAFile != "" && config.CertFile == "" {
+		return false
+	}
+	if config.MinTLSVersion != "" {
+		if _, ok := tlsVersions[config.MinTLSVersion]; !ok {
+			return false
+		}
+	}
+	for _, name := range config.CipherSuites {
+		if _, ok := tlsCipherSuites[name]; !ok {
+			return false
+		}
+	}
+	if config.ShutdownTimeout < 0 || config.ReadTimeout < 0 || config.WriteTimeout < 0 || config.IdleTimeout < 0 {
+		return false
+	}
 	log.Println("ConfigHTTP check pass")
 	return true
 }
 
+// UseTLS 是否使用TLS
+func (config ConfigHTTP) UseTLS() bool {
+	return config.CertFile != "" && config.KeyFile != ""
+}
+
 // ConfigRPC --
 type ConfigRPC struct {
-	Network string
-	Address string
-	Call    string
+	Network  string // tcp、tcp4、tcp6、unix
+	Address  string // unix时为socket文件路径
+	Call     string
+	MaxConns int // 同时连接数，0不限制
 }
 
 // Check --
 func (config ConfigRPC) Check() bool {
-	if config.Network == "" {
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6":
+		if config.Address == "" || !strings.Contains(config.Address, ":") {
+			return false
+		}
+	case "unix":
+		if config.Address == "" {
+			return false
+		}
+	default:
 		return false
 	}
-	if config.Address == "" || !strings.Contains(config.Address, ":") {
+	if config.MaxConns < 0 {
 		return false
 	}
 	if config.Call == "" {
@@ -0,0 +1,54 @@
+package gglmm
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestConfigHTTPCheck(t *testing.T) {
+	cases := []struct {
+		config ConfigHTTP
+		pass   bool
+	}{
+		{ConfigHTTP{Address: ":8080"}, true},
+		{ConfigHTTP{Address: ":8443", CertFile: "cert.pem", KeyFile: "key.pem", MinTLSVersion: "1.3"}, true},
+		{ConfigHTTP{Address: ":8443", CertFile: "cert.pem"}, false},
+		{ConfigHTTP{Address: ":8443", ClientCAFile: "ca.pem"}, false},
+		{ConfigHTTP{Address: ":8443", CertFile: "cert.pem", KeyFile: "key.pem", MinTLSVersion: "2.0"}, false},
+		{ConfigHTTP{Address: ":8443", CertFile: "cert.pem", KeyFile: "key.pem", CipherSuites: []string{"TLS_UNKNOWN"}}, false},
+		{ConfigHTTP{Address: ":8080", ReadTimeout: -1}, false},
+	}
+	for _, c := range cases {
+		if c.config.Check() != c.pass {
+			t.Fatal(c.config)
+		}
+	}
+}
+
+func TestConfigHTTPTLSConfig(t *testing.T) {
+	tlsConfig, err := ConfigHTTP{Address: ":8080"}.TLSConfig()
+	if err != nil || tlsConfig != nil {
+		t.Fatal(tlsConfig, err)
+	}
+	config := ConfigHTTP{
+		Address:       ":8443",
+		CertFile:      "cert.pem",
+		KeyFile:       "key.pem",
+		MinTLSVersion: "1.1",
+		CipherSuites:  []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	}
+	tlsConfig, err = config.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS11 {
+		t.Fatal(tlsConfig.MinVersion)
+	}
+	if len(tlsConfig.CipherSuites) != 1 || tlsConfig.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatal(tlsConfig.CipherSuites)
+	}
+	config.ClientCAFile = "not-exist.pem"
+	if _, err := config.TLSConfig(); err == nil {
+		t.Fatal("client ca")
+	}
+}
@@ -0,0 +1,162 @@
+package gglmm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"unsafe"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ContextGormKey gorm中保存context.Context的键
+// gorm回调、模型钩子中通过scope.Get(ContextGormKey)获取
+const ContextGormKey = "gglmm:context"
+
+// ContextGormCallback gorm中检查context的回调名
+const ContextGormCallback = "gglmm:context"
+
+// RegisterGormContextCallbacks 为gorm.DB注册context回调，语句使用context执行，context取消或超时后中止正在执行的语句
+// GormOpen、ServerOptions.GormDB已注册，重复注册无影响
+func RegisterGormContextCallbacks(gormDB *gorm.DB) *gorm.DB {
+	callback := gormDB.Callback()
+	if callback.Query().Get(ContextGormCallback) != nil {
+		return gormDB
+	}
+	callback.Query().Before("gorm:query").Register(ContextGormCallback, gormContextCallback)
+	for _, processor := range []*gorm.CallbackProcessor{callback.Create(), callback.Update(), callback.Delete()} {
+		if begin := processor.Get("gorm:begin_transaction"); begin != nil {
+			processor.Replace("gorm:begin_transaction", gormContextBeginCallback(begin))
+		}
+	}
+	if rowQuery := callback.RowQuery().Get("gorm:row_query"); rowQuery != nil {
+		callback.RowQuery().Replace("gorm:row_query", gormContextRowQueryCallback(rowQuery))
+	}
+	return gormDB
+}
+
+func gormContext(scope *gorm.Scope) context.Context {
+	if value, ok := scope.Get(ContextGormKey); ok {
+		if ctx, ok := value.(context.Context); ok {
+			return ctx
+		}
+	}
+	return nil
+}
+
+// gormContextCallback scope使用context执行语句，只替换scope内的连接，原gorm.DB不变
+func gormContextCallback(scope *gorm.Scope) {
+	if ctx := gormContext(scope); ctx != nil {
+		setGormSQLCommon(scope.DB(), withSQLContext(ctx, scope.SQLDB()))
+	}
+}
+
+// gormContextBeginCallback 使用context开启事务，context结束时事务中正在执行的语句中止
+func gormContextBeginCallback(begin func(scope *gorm.Scope)) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		gormContextCallback(scope)
+		begin(scope)
+		gormContextCallback(scope)
+	}
+}
+
+func gormContextRowQueryCallback(rowQuery func(scope *gorm.Scope)) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		gormContextCallback(scope)
+		rowQuery(scope)
+	}
+}
+
+// setGormSQLCommon 替换gorm.DB的连接，gorm未提供设置方法
+func setGormSQLCommon(gormDB *gorm.DB, db gorm.SQLCommon) {
+	field := reflect.ValueOf(gormDB).Elem().FieldByName("db")
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(&db).Elem())
+}
+
+type sqlContextCommon interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// withSQLContext 返回使用ctx执行语句的连接，*sql.DB开启的事务同样使用ctx
+func withSQLContext(ctx context.Context, db gorm.SQLCommon) gorm.SQLCommon {
+	switch db := db.(type) {
+	case *sql.DB:
+		return &contextSQLDB{contextSQLCommon: contextSQLCommon{ctx: ctx, db: db}, sqlDB: db}
+	case *sql.Tx:
+		return &contextSQLTx{contextSQLCommon: contextSQLCommon{ctx: ctx, db: db}, sqlTx: db}
+	case *contextSQLDB:
+		return withSQLContext(ctx, db.sqlDB)
+	case *contextSQLTx:
+		return withSQLContext(ctx, db.sqlTx)
+	}
+	return db
+}
+
+// contextSQLCommon 使用ctx执行语句的gorm.SQLCommon
+type contextSQLCommon struct {
+	ctx context.Context
+	db  sqlContextCommon
+}
+
+func (common *contextSQLCommon) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return common.db.ExecContext(common.ctx, query, args...)
+}
+
+func (common *contextSQLCommon) Prepare(query string) (*sql.Stmt, error) {
+	return common.db.PrepareContext(common.ctx, query)
+}
+
+func (common *contextSQLCommon) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return common.db.QueryContext(common.ctx, query, args...)
+}
+
+func (common *contextSQLCommon) QueryRow(query string, args ...interface{}) *sql.Row {
+	return common.db.QueryRowContext(common.ctx, query, args...)
+}
+
+// contextSQLDB 使用ctx的*sql.DB，gorm开启事务时使用ctx
+type contextSQLDB struct {
+	contextSQLCommon
+	sqlDB *sql.DB
+}
+
+func (db *contextSQLDB) Begin() (*sql.Tx, error) {
+	return db.sqlDB.BeginTx(db.ctx, nil)
+}
+
+func (db *contextSQLDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return db.sqlDB.BeginTx(ctx, opts)
+}
+
+// contextSQLTx 使用ctx的*sql.Tx
+type contextSQLTx struct {
+	contextSQLCommon
+	sqlTx *sql.Tx
+}
+
+func (tx *contextSQLTx) Commit() error {
+	return tx.sqlTx.Commit()
+}
+
+func (tx *contextSQLTx) Rollback() error {
+	return tx.sqlTx.Rollback()
+}
+
+// WithContext 返回使用ctx的DB，ctx取消或超时后中止正在执行的语句，事务随ctx回滚
+// 沿用原gorm.DB的日志、回调等设置
+func (gglmmDB *DB) WithContext(ctx context.Context) *DB {
+	db := gglmmDB.clone(gglmmDB.gormDB.Set(ContextGormKey, ctx))
+	db.ctx = ctx
+	return db
+}
+
+// Context 返回DB的context，未设置时为context.Background()
+func (gglmmDB *DB) Context() context.Context {
+	if gglmmDB.ctx == nil {
+		return context.Background()
+	}
+	return gglmmDB.ctx
+}
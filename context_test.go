@@ -0,0 +1,122 @@
+package gglmm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func TestDBWithContext(t *testing.T) {
+	db, database := newTestDB(t)
+	RegisterGormContextCallbacks(db.GormDB())
+	queried := false
+	db.GormDB().Callback().Query().After("gorm:query").Register("test:query", func(scope *gorm.Scope) {
+		queried = true
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	ctxDB := db.WithContext(ctx)
+	if ctxDB.Context() != ctx {
+		t.Fatal("context")
+	}
+	if ctxDB.GormDB().DB() == nil {
+		t.Fatal("sql.DB")
+	}
+	model := testDefaultModel{}
+	if err := ctxDB.First(&model, uint64(1)); err != gorm.ErrRecordNotFound {
+		t.Fatal(err)
+	}
+	if !queried {
+		t.Fatal("callback")
+	}
+	statements := database.Statements()
+	if len(statements) != 1 || !strings.HasPrefix(statements[0], "SELECT") {
+		t.Fatal(statements)
+	}
+
+	cancel()
+	if err := ctxDB.First(&model, uint64(1)); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := ctxDB.Create(&testDefaultModel{}); err != context.Canceled {
+		t.Fatal(err)
+	}
+	count := 0
+	if err := ctxDB.GormDB().Model(&model).Count(&count).Error; err != context.Canceled {
+		t.Fatal(err)
+	}
+	if _, err := ctxDB.GormDB().Model(&model).Rows(); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if statements := database.Statements(); len(statements) != 1 {
+		t.Fatal(statements)
+	}
+	if err := db.First(&model, uint64(1)); err != gorm.ErrRecordNotFound {
+		t.Fatal(err)
+	}
+}
+
+func TestDBWithContextTransaction(t *testing.T) {
+	db, database := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	err := db.WithContext(ctx).Transaction(func(tx *DB) error {
+		if tx.Context() != ctx {
+			t.Fatal("context")
+		}
+		cancel()
+		return tx.First(&testDefaultModel{}, uint64(1))
+	})
+	if err != context.Canceled {
+		t.Fatal(err)
+	}
+	for _, statement := range database.Statements() {
+		if statement != "BEGIN" && statement != "ROLLBACK" {
+			t.Fatal(database.Statements())
+		}
+	}
+}
+
+func TestDBWithContextCancelRunning(t *testing.T) {
+	db, database := newTestDB(t)
+	chanBlocked := database.Block()
+	cancelBlocked := func() context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-chanBlocked
+			cancel()
+		}()
+		return ctx
+	}
+
+	// 正在执行的COUNT、SELECT随ctx中止
+	response := PageResponse{List: &[]testDefaultModel{}}
+	if err := db.WithContext(cancelBlocked()).Page(&response, &PageRequest{}); err != context.Canceled {
+		t.Fatal(err)
+	}
+	statements := database.Statements()
+	if len(statements) != 1 || !strings.Contains(statements[0], "count(*)") {
+		t.Fatal(statements)
+	}
+	request := PageRequest{SkipTotal: true}
+	if err := db.WithContext(cancelBlocked()).Page(&response, &request); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if statements = database.Statements(); len(statements) != 2 || !strings.HasPrefix(statements[1], "SELECT") {
+		t.Fatal(statements)
+	}
+
+	// 事务中正在执行的语句随ctx中止
+	err := db.WithContext(cancelBlocked()).Transaction(func(tx *DB) error {
+		return tx.GormDB().Exec("UPDATE a").Error
+	})
+	if err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(cancelBlocked()).Create(&testDefaultModel{}); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if db.GormDB().DB() == nil || db.WithContext(context.Background()).GormDB().DB() == nil {
+		t.Fatal("sql.DB")
+	}
+}
@@ -0,0 +1,253 @@
+package gglmm
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Err
+var (
+	ErrCursor         = errors.New("游标错误")
+	ErrCursorNullable = errors.New("游标分页不支持可为空的排序列")
+)
+
+var typeOfValuer = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// cursorTimeLayout 游标中时间值的格式
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+// cursorOrder 游标排序列
+type cursorOrder struct {
+	column string
+	desc   bool
+}
+
+// cursorValue 游标内容：排序以及最后一行排序列的值
+type cursorValue struct {
+	Order  string        `json:"o"`
+	Values []interface{} `json:"v"`
+}
+
+// cursorOrders 解析排序，并以主键作为最后的排序列保证唯一
+func cursorOrders(order string, primaryKey string) []cursorOrder {
+	orders := make([]cursorOrder, 0)
+	hasPrimaryKey := false
+	for _, item := range strings.Split(order, ",") {
+		parts := strings.Fields(item)
+		if len(parts) == 0 {
+			continue
+		}
+		desc := len(parts) == 2 && strings.ToLower(parts[1]) == "desc"
+		orders = append(orders, cursorOrder{column: parts[0], desc: desc})
+		if parts[0] == primaryKey {
+			hasPrimaryKey = true
+		}
+	}
+	if !hasPrimaryKey {
+		orders = append(orders, cursorOrder{column: primaryKey})
+	}
+	return orders
+}
+
+// checkCursorOrders 排序列必须存在且不可为空（指针、sql.NullXXX等），否则无法生成下一页条件
+func checkCursorOrders(model interface{}, orders []cursorOrder) error {
+	scope := &gorm.Scope{Value: model}
+	for _, order := range orders {
+		field, ok := scope.FieldByName(order.column)
+		if !ok {
+			return ErrCursor
+		}
+		fieldType := field.Field.Type()
+		if fieldType.Kind() == reflect.Ptr || fieldType.Implements(typeOfValuer) || reflect.PtrTo(fieldType).Implements(typeOfValuer) {
+			return ErrCursorNullable
+		}
+	}
+	return nil
+}
+
+func cursorOrdersString(orders []cursorOrder) string {
+	items := make([]string, 0, len(orders))
+	for _, order := range orders {
+		if order.desc {
+			items = append(items, order.column+" desc")
+		} else {
+			items = append(items, order.column+" asc")
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// encodeCursor 根据行的排序列生成游标
+func encodeCursor(row interface{}, orders []cursorOrder) (string, error) {
+	scope := &gorm.Scope{Value: row}
+	values := make([]interface{}, 0, len(orders))
+	for _, order := range orders {
+		field, ok := scope.FieldByName(order.column)
+		if !ok {
+			return "", ErrCursor
+		}
+		value := field.Field.Interface()
+		switch value := value.(type) {
+		case time.Time:
+			values = append(values, value.Format(cursorTimeLayout))
+		case *time.Time:
+			if value == nil {
+				return "", ErrCursor
+			}
+			values = append(values, value.Format(cursorTimeLayout))
+		default:
+			values = append(values, value)
+		}
+	}
+	data, err := json.Marshal(cursorValue{Order: cursorOrdersString(orders), Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 解析游标，排序必须与生成游标时一致
+func decodeCursor(cursor string, orders []cursorOrder) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrCursor
+	}
+	value := cursorValue{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, ErrCursor
+	}
+	if value.Order != cursorOrdersString(orders) || len(value.Values) != len(orders) {
+		return nil, ErrCursor
+	}
+	for _, item := range value.Values {
+		switch item.(type) {
+		case string, json.Number, bool:
+		default:
+			return nil, ErrCursor
+		}
+	}
+	return value.Values, nil
+}
+
+// cursorSQL 游标条件
+// (a > ?) or (a = ? and b > ?) or ...；before为true时比较方向相反
+func cursorSQL(orders []cursorOrder, values []interface{}, before bool) (string, []interface{}) {
+	wheres := make([]string, 0, len(orders))
+	args := make([]interface{}, 0)
+	for i, order := range orders {
+		conditions := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, orders[j].column+" = ?")
+			args = append(args, values[j])
+		}
+		if order.desc != before {
+			conditions = append(conditions, order.column+" < ?")
+		} else {
+			conditions = append(conditions, order.column+" > ?")
+		}
+		args = append(args, values[i])
+		wheres = append(wheres, "("+strings.Join(conditions, " and ")+")")
+	}
+	return "(" + strings.Join(wheres, " or ") + ")", args
+}
+
+func reverseOrders(orders []cursorOrder) []cursorOrder {
+	reversed := make([]cursorOrder, 0, len(orders))
+	for _, order := range orders {
+		reversed = append(reversed, cursorOrder{column: order.column, desc: !order.desc})
+	}
+	return reversed
+}
+
+func reverseSlice(slice reflect.Value) {
+	swap := reflect.Swapper(slice.Interface())
+	for i, j := 0, slice.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
+
+// pageCursor 游标分页
+func (gglmmDB *DB) pageCursor(response *PageResponse, request *PageRequest) error {
+	pageSize := request.Pagination.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	response.Cursor = &CursorPagination{
+		PageSize: pageSize,
+	}
+	model := reflect.New(modelType(response.List)).Interface()
+	primaryKey := (&gorm.Scope{Value: model}).PrimaryKey()
+	if primaryKey == "" {
+		return ErrCursor
+	}
+	orders := cursorOrders(request.Order, primaryKey)
+	if err := checkCursorOrders(model, orders); err != nil {
+		return err
+	}
+	queryOrders := orders
+	before := request.Cursor.Before != ""
+
+	filterRequest := request.FilterRequest
+	filterRequest.Order = ""
+	gormDB := gormPreloads(gglmmDB.gormDB, request.Preloads)
+	gormDB, err := gormFilterRequest(gormDB, &filterRequest)
+	if err != nil {
+		return err
+	}
+	if before || request.Cursor.After != "" {
+		cursor := request.Cursor.After
+		if before {
+			cursor = request.Cursor.Before
+			queryOrders = reverseOrders(orders)
+		}
+		values, err := decodeCursor(cursor, orders)
+		if err != nil {
+			return err
+		}
+		query, args := cursorSQL(orders, values, before)
+		gormDB = gormDB.Where(query, args...)
+	}
+	for _, order := range queryOrders {
+		if order.desc {
+			gormDB = gormDB.Order(order.column + " desc")
+		} else {
+			gormDB = gormDB.Order(order.column + " asc")
+		}
+	}
+	if err := gormDB.Limit(pageSize + 1).Find(response.List).Error; err != nil {
+		return err
+	}
+
+	list := reflect.ValueOf(response.List).Elem()
+	hasMore := list.Len() > pageSize
+	if hasMore {
+		list.Set(list.Slice(0, pageSize))
+	}
+	if before {
+		reverseSlice(list)
+	}
+	if list.Len() == 0 {
+		return nil
+	}
+	if (before && hasMore) || (!before && request.Cursor.After != "") {
+		if response.Cursor.PrevCursor, err = encodeCursor(list.Index(0).Addr().Interface(), orders); err != nil {
+			return err
+		}
+	}
+	if before || hasMore {
+		if response.Cursor.NextCursor, err = encodeCursor(list.Index(list.Len()-1).Addr().Interface(), orders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package gglmm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	orders := cursorOrders("created_at desc", "id")
+	if len(orders) != 2 || orders[0].column != "created_at" || !orders[0].desc || orders[1].column != "id" || orders[1].desc {
+		t.Fatal(orders)
+	}
+	row := testFieldsModel{Model: Model{ID: 9, CreatedAt: time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)}}
+	cursor, err := encodeCursor(&row, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := decodeCursor(cursor, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != "2020-05-01 08:00:00" || values[1] != json.Number("9") {
+		t.Fatal(values)
+	}
+	if _, err := decodeCursor(cursor, cursorOrders("name", "id")); err != ErrCursor {
+		t.Fatal(err)
+	}
+	if _, err := decodeCursor("not a cursor", orders); err != ErrCursor {
+		t.Fatal(err)
+	}
+
+	query, args := cursorSQL(orders, values, false)
+	if query != "((created_at < ?) or (created_at = ? and id > ?))" || len(args) != 3 {
+		t.Fatal(query, args)
+	}
+	query, _ = cursorSQL(orders, values, true)
+	if query != "((created_at > ?) or (created_at = ? and id < ?))" {
+		t.Fatal(query)
+	}
+}
+
+type testCursorModel struct {
+	Model
+	Name     string         `json:"name"`
+	Nickname *string        `json:"nickname"`
+	Remark   sql.NullString `json:"remark"`
+}
+
+func TestCheckCursorOrders(t *testing.T) {
+	model := &testCursorModel{}
+	if err := checkCursorOrders(model, cursorOrders("name desc, created_at", "id")); err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range []string{"nickname", "remark", "deleted_at"} {
+		if err := checkCursorOrders(model, cursorOrders(order, "id")); err != ErrCursorNullable {
+			t.Fatal(order, err)
+		}
+	}
+	if err := checkCursorOrders(model, cursorOrders("unknown", "id")); err != ErrCursor {
+		t.Fatal(err)
+	}
+}
+
+// pageTestCursor 游标分页，返回查询语句及参数
+func pageTestCursor(t *testing.T, db *DB, database *testSQL, cursor *Cursor, ids ...int64) (*PageResponse, string, []driver.Value) {
+	t.Helper()
+	rows := make([][]driver.Value, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, []driver.Value{id, "n"})
+	}
+	database.SetRows([]string{"id", "name"}, rows...)
+	list := make([]testDefaultModel, 0)
+	response := PageResponse{List: &list}
+	request := PageRequest{
+		FilterRequest: FilterRequest{Order: "name desc"},
+		Pagination:    Pagination{PageSize: 2},
+		Cursor:        cursor,
+	}
+	if err := db.Page(&response, &request); err != nil {
+		t.Fatal(err)
+	}
+	statements := database.Statements()
+	index := len(statements) - 1
+	return &response, statements[index], database.Args(index)
+}
+
+func checkTestCursorList(t *testing.T, response *PageResponse, ids ...uint64) {
+	t.Helper()
+	list := *response.List.(*[]testDefaultModel)
+	if len(list) != len(ids) {
+		t.Fatal(list)
+	}
+	for index, id := range ids {
+		if list[index].ID != id {
+			t.Fatal(list)
+		}
+	}
+}
+
+func checkTestCursor(t *testing.T, cursor string, id uint64) {
+	t.Helper()
+	values, err := decodeCursor(cursor, cursorOrders("name desc", "id"))
+	if err != nil {
+		t.Fatal(cursor, err)
+	}
+	if values[0] != "n" || values[1] != json.Number(strconv.FormatUint(id, 10)) {
+		t.Fatal(values)
+	}
+}
+
+func TestDBPageCursor(t *testing.T) {
+	db, database := newTestDB(t)
+
+	// 第一页：多取一行判断是否有下一页，没有上一页
+	response, query, _ := pageTestCursor(t, db, database, &Cursor{}, 1, 2, 3)
+	if !strings.HasSuffix(query, "ORDER BY name desc,id asc LIMIT 3") || strings.Contains(query, "name <") {
+		t.Fatal(query)
+	}
+	checkTestCursorList(t, response, 1, 2)
+	if response.Cursor.PageSize != 2 || response.Cursor.PrevCursor != "" {
+		t.Fatal(response.Cursor)
+	}
+	checkTestCursor(t, response.Cursor.NextCursor, 2)
+
+	// 向后翻页：按原排序取after之后的行
+	response, query, args := pageTestCursor(t, db, database, &Cursor{After: response.Cursor.NextCursor}, 3)
+	if !strings.Contains(query, "((name < ?) or (name = ? and id > ?))") || !strings.HasSuffix(query, "ORDER BY name desc,id asc LIMIT 3") {
+		t.Fatal(query)
+	}
+	if !reflect.DeepEqual(args, []driver.Value{"n", "n", "2"}) {
+		t.Fatal(args)
+	}
+	checkTestCursorList(t, response, 3)
+	if response.Cursor.NextCursor != "" {
+		t.Fatal(response.Cursor)
+	}
+	checkTestCursor(t, response.Cursor.PrevCursor, 3)
+
+	// 向前翻页：反向排序取before之前的行，结果恢复原顺序
+	response, query, args = pageTestCursor(t, db, database, &Cursor{Before: response.Cursor.PrevCursor}, 2, 1)
+	if !strings.Contains(query, "((name > ?) or (name = ? and id < ?))") || !strings.HasSuffix(query, "ORDER BY name asc,id desc LIMIT 3") {
+		t.Fatal(query)
+	}
+	if !reflect.DeepEqual(args, []driver.Value{"n", "n", "3"}) {
+		t.Fatal(args)
+	}
+	checkTestCursorList(t, response, 1, 2)
+	if response.Cursor.PrevCursor != "" {
+		t.Fatal(response.Cursor)
+	}
+	checkTestCursor(t, response.Cursor.NextCursor, 2)
+
+	// 向前翻页且前面还有行：去掉多取的一行
+	response, _, _ = pageTestCursor(t, db, database, &Cursor{Before: response.Cursor.NextCursor}, 5, 4, 3)
+	checkTestCursorList(t, response, 4, 5)
+	checkTestCursor(t, response.Cursor.PrevCursor, 4)
+	checkTestCursor(t, response.Cursor.NextCursor, 5)
+
+	// 没有行时不返回游标
+	response, _, _ = pageTestCursor(t, db, database, &Cursor{After: response.Cursor.NextCursor})
+	checkTestCursorList(t, response)
+	if response.Cursor.PrevCursor != "" || response.Cursor.NextCursor != "" {
+		t.Fatal(response.Cursor)
+	}
+}
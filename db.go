@@ -1,7 +1,10 @@
 package gglmm
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"reflect"
 
 	"github.com/jinzhu/gorm"
 )
@@ -11,17 +14,27 @@ var (
 	ErrCreateNotNewRecord = errors.New("新建失败，已存在主键")
 	ErrUpdateID           = errors.New("更新失败，请设置主键")
 	ErrDeleteID           = errors.New("删除失败，请设置主键")
+	ErrWriteField         = errors.New("字段不可写")
 )
 
 // DB --
 type DB struct {
-	gormDB *gorm.DB
+	gormDB      *gorm.DB
+	modelFields map[reflect.Type]*ModelFields
+	txDepth     int
+	ctx         context.Context
 }
 
-// NewDB 新建DB
+// NewDB 新建DB，使用默认实例的gorm.DB
 func NewDB() *DB {
+	return defaultServer.NewDB()
+}
+
+// NewDB 新建DB，使用实例的gorm.DB
+func (server *Server) NewDB() *DB {
 	return &DB{
-		gormDB: DefaultGormDB(),
+		gormDB:      server.GormDB(),
+		modelFields: make(map[reflect.Type]*ModelFields),
 	}
 }
 
@@ -30,6 +43,28 @@ func (gglmmDB *DB) GormDB() *gorm.DB {
 	return gglmmDB.gormDB
 }
 
+func modelType(model interface{}) reflect.Type {
+	reflectType := reflect.TypeOf(model)
+	for reflectType.Kind() == reflect.Slice || reflectType.Kind() == reflect.Ptr {
+		reflectType = reflectType.Elem()
+	}
+	return reflectType
+}
+
+// RegisterModelFields 声明模型的可过滤、可排序、可模糊查询列
+func (gglmmDB *DB) RegisterModelFields(model interface{}, fields *ModelFields) *DB {
+	gglmmDB.modelFields[modelType(model)] = fields
+	return gglmmDB
+}
+
+// ModelFields 模型字段声明，未声明时根据gorm标签解析
+func (gglmmDB *DB) ModelFields(model interface{}) *ModelFields {
+	if fields, ok := gglmmDB.modelFields[modelType(model)]; ok {
+		return fields
+	}
+	return NewModelFields(model)
+}
+
 // NewRecord --
 func (gglmmDB *DB) NewRecord(model interface{}) bool {
 	return gglmmDB.gormDB.NewRecord(model)
@@ -47,6 +82,87 @@ func (gglmmDB *DB) Begin() *gorm.DB {
 	return gglmmDB.gormDB.Begin()
 }
 
+func (gglmmDB *DB) clone(gormDB *gorm.DB) *DB {
+	db := *gglmmDB
+	db.gormDB = gormDB
+	return &db
+}
+
+// Unscoped 返回包括已软删除记录的DB
+func (gglmmDB *DB) Unscoped() *DB {
+	return gglmmDB.clone(gglmmDB.gormDB.Unscoped())
+}
+
+// InTransaction 是否在事务中
+func (gglmmDB *DB) InTransaction() bool {
+	return gglmmDB.txDepth > 0
+}
+
+// Transaction 在事务中执行fn，fn返回nil时提交，返回错误或panic时回滚
+// 在事务中再次调用时使用保存点
+func (gglmmDB *DB) Transaction(fn func(tx *DB) error) (err error) {
+	if gglmmDB.InTransaction() {
+		return gglmmDB.savepoint(fn)
+	}
+	gormTx := gglmmDB.gormDB.BeginTx(gglmmDB.Context(), nil)
+	if err := gormTx.Error; err != nil {
+		return err
+	}
+	if gglmmDB.ctx != nil {
+		// 事务中的语句使用context执行，包括不经过gorm回调的Exec
+		setGormSQLCommon(gormTx, withSQLContext(gglmmDB.ctx, gormTx.CommonDB()))
+	}
+	tx := gglmmDB.clone(gormTx)
+	tx.txDepth = 1
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if recover := recover(); recover != nil {
+			gormTx.Rollback()
+			panic(recover)
+		}
+		gormTx.Rollback()
+	}()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = gormTx.Commit().Error; err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func (gglmmDB *DB) savepoint(fn func(tx *DB) error) (err error) {
+	name := fmt.Sprintf("gglmm_savepoint_%d", gglmmDB.txDepth)
+	if err := gglmmDB.gormDB.Exec("SAVEPOINT " + name).Error; err != nil {
+		return err
+	}
+	tx := gglmmDB.clone(gglmmDB.gormDB)
+	tx.txDepth = gglmmDB.txDepth + 1
+	released := false
+	defer func() {
+		if released {
+			return
+		}
+		if recover := recover(); recover != nil {
+			gglmmDB.gormDB.Exec("ROLLBACK TO SAVEPOINT " + name)
+			panic(recover)
+		}
+		gglmmDB.gormDB.Exec("ROLLBACK TO SAVEPOINT " + name)
+	}()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = gglmmDB.gormDB.Exec("RELEASE SAVEPOINT " + name).Error; err != nil {
+		return err
+	}
+	released = true
+	return nil
+}
+
 // Create 新建
 func (gglmmDB *DB) Create(model interface{}) error {
 	if !gglmmDB.gormDB.NewRecord(model) {
@@ -88,6 +204,14 @@ func (gglmmDB *DB) first(model interface{}, idRequest *IDRequest) error {
 }
 
 func (gglmmDB *DB) firstByFilter(model interface{}, filterRequest *FilterRequest) error {
+	if err := gglmmDB.ModelFields(model).CheckFilterRequest(filterRequest); err != nil {
+		return err
+	}
+	return gglmmDB.firstFilter(model, filterRequest)
+}
+
+// firstFilter 不检查过滤请求，服务在filterFunc追加服务端过滤条件前检查客户端请求
+func (gglmmDB *DB) firstFilter(model interface{}, filterRequest *FilterRequest) error {
 	gormDB := gormPreloads(gglmmDB.gormDB, filterRequest.Preloads)
 	gormDB, err := gormFilterRequest(gormDB, filterRequest)
 	if err != nil {
@@ -101,6 +225,14 @@ func (gglmmDB *DB) firstByFilter(model interface{}, filterRequest *FilterRequest
 
 // List 根据条件列表查询
 func (gglmmDB *DB) List(models interface{}, filterRequest *FilterRequest) error {
+	if err := gglmmDB.ModelFields(models).CheckFilterRequest(filterRequest); err != nil {
+		return err
+	}
+	return gglmmDB.list(models, filterRequest)
+}
+
+// list 不检查过滤请求
+func (gglmmDB *DB) list(models interface{}, filterRequest *FilterRequest) error {
 	gormDB := gormPreloads(gglmmDB.gormDB, filterRequest.Preloads)
 	gormDB, err := gormFilterRequest(gormDB, filterRequest)
 	if err != nil {
@@ -114,25 +246,48 @@ func (gglmmDB *DB) List(models interface{}, filterRequest *FilterRequest) error
 
 // Page 根据条件分页查询
 func (gglmmDB *DB) Page(response *PageResponse, request *PageRequest) error {
+	if err := gglmmDB.ModelFields(response.List).CheckFilterRequest(&request.FilterRequest); err != nil {
+		return err
+	}
+	return gglmmDB.page(response, request)
+}
+
+// page 不检查过滤请求
+func (gglmmDB *DB) page(response *PageResponse, request *PageRequest) error {
+	if request.Cursor != nil {
+		return gglmmDB.pageCursor(response, request)
+	}
 	gormDB := gormPreloads(gglmmDB.gormDB, request.Preloads)
 	gormDB, err := gormFilterRequest(gormDB, &request.FilterRequest)
 	if err != nil {
 		return err
 	}
 	pageIndex := request.Pagination.PageIndex
-	if pageIndex == 0 {
+	if pageIndex <= 0 {
 		pageIndex = FirstPageIndex
 	}
 	pageSize := request.Pagination.PageSize
-	if pageSize == 0 {
+	if pageSize <= 0 {
 		pageSize = DefaultPageSize
 	}
 	response.Pagination.PageIndex = pageIndex
 	response.Pagination.PageSize = pageSize
 	offset := (pageIndex - 1) * pageSize
+	if request.SkipTotal {
+		if err = gormDB.Limit(pageSize + 1).Offset(offset).Find(response.List).Error; err != nil {
+			return err
+		}
+		list := reflect.ValueOf(response.List).Elem()
+		response.Pagination.HasMore = list.Len() > pageSize
+		if response.Pagination.HasMore {
+			list.Set(list.Slice(0, pageSize))
+		}
+		return nil
+	}
 	if err = gormDB.Model(response.List).Count(&response.Pagination.Total).Limit(response.Pagination.PageSize).Offset(offset).Find(response.List).Error; err != nil {
 		return err
 	}
+	response.Pagination.HasMore = offset+pageSize < response.Pagination.Total
 	return nil
 }
 
@@ -0,0 +1,437 @@
+package gglmm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+// testSQL 测试用的数据库，记录执行的语句，查询返回columns、values
+type testSQL struct {
+	mutex        sync.Mutex
+	statements   []string
+	args         [][]driver.Value
+	columns      []string
+	values       [][]driver.Value
+	lastInsertID int64
+	count        []driver.Value
+	chanBlocked  chan string
+}
+
+var (
+	testSQLMutex sync.Mutex
+	testSQLs     = make(map[string]*testSQL)
+)
+
+func init() {
+	sql.Register("gglmm_test", testSQLDriver{})
+}
+
+// newTestDB 新建使用testSQL的DB
+func newTestDB(t *testing.T) (*DB, *testSQL) {
+	testSQLMutex.Lock()
+	name := fmt.Sprintf("test_%d", len(testSQLs))
+	database := &testSQL{}
+	testSQLs[name] = database
+	testSQLMutex.Unlock()
+
+	sqlDB, err := sql.Open("gglmm_test", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDB, err := gorm.Open("common", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDB.SingularTable(true)
+	RegisterGormContextCallbacks(gormDB)
+	return &DB{
+		gormDB:      gormDB,
+		modelFields: make(map[reflect.Type]*ModelFields),
+	}, database
+}
+
+func (database *testSQL) record(statement string, args ...driver.NamedValue) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	database.statements = append(database.statements, strings.TrimSpace(statement))
+	values := make([]driver.Value, len(args))
+	for index, arg := range args {
+		values[index] = arg.Value
+	}
+	database.args = append(database.args, values)
+}
+
+// Statements 返回执行的语句
+func (database *testSQL) Statements() []string {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	return append([]string(nil), database.statements...)
+}
+
+// Args 返回第index条语句的参数
+func (database *testSQL) Args(index int) []driver.Value {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	return database.args[index]
+}
+
+// SetCount 设置count(*)查询返回的总数
+func (database *testSQL) SetCount(count int64) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	database.count = []driver.Value{count}
+}
+
+// Block 之后的语句阻塞到context结束，返回的chan在语句开始阻塞时收到该语句
+func (database *testSQL) Block() <-chan string {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	database.chanBlocked = make(chan string, 16)
+	return database.chanBlocked
+}
+
+func (database *testSQL) wait(ctx context.Context, query string) error {
+	database.mutex.Lock()
+	chanBlocked := database.chanBlocked
+	database.mutex.Unlock()
+	if chanBlocked == nil {
+		return nil
+	}
+	chanBlocked <- strings.TrimSpace(query)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// SetRows 设置查询返回的行
+func (database *testSQL) SetRows(columns []string, values ...[]driver.Value) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	database.columns = columns
+	database.values = values
+}
+
+type testSQLDriver struct{}
+
+func (testSQLDriver) Open(name string) (driver.Conn, error) {
+	testSQLMutex.Lock()
+	defer testSQLMutex.Unlock()
+	database, ok := testSQLs[name]
+	if !ok {
+		return nil, errors.New("unknown test database " + name)
+	}
+	return &testSQLConn{database: database}, nil
+}
+
+type testSQLConn struct {
+	database *testSQL
+}
+
+func (conn *testSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &testSQLStmt{conn: conn, query: query}, nil
+}
+
+func (conn *testSQLConn) Close() error {
+	return nil
+}
+
+func (conn *testSQLConn) Begin() (driver.Tx, error) {
+	return conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (conn *testSQLConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	conn.database.record("BEGIN")
+	return &testSQLTx{database: conn.database}, nil
+}
+
+func (conn *testSQLConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn.database.record(query, args...)
+	if err := conn.database.wait(ctx, query); err != nil {
+		return nil, err
+	}
+	conn.database.mutex.Lock()
+	defer conn.database.mutex.Unlock()
+	if strings.HasPrefix(strings.TrimSpace(query), "INSERT") {
+		conn.database.lastInsertID++
+	}
+	return testSQLResult{lastInsertID: conn.database.lastInsertID}, nil
+}
+
+func (conn *testSQLConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	conn.database.record(query, args...)
+	if err := conn.database.wait(ctx, query); err != nil {
+		return nil, err
+	}
+	conn.database.mutex.Lock()
+	defer conn.database.mutex.Unlock()
+	if conn.database.count != nil && strings.Contains(query, "count(*)") {
+		return &testSQLRows{columns: []string{"count"}, values: [][]driver.Value{conn.database.count}}, nil
+	}
+	return &testSQLRows{columns: conn.database.columns, values: conn.database.values}, nil
+}
+
+type testSQLStmt struct {
+	conn  *testSQLConn
+	query string
+}
+
+func (stmt *testSQLStmt) Close() error {
+	return nil
+}
+
+func (stmt *testSQLStmt) NumInput() int {
+	return -1
+}
+
+func (stmt *testSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return stmt.conn.ExecContext(context.Background(), stmt.query, nil)
+}
+
+func (stmt *testSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return stmt.conn.QueryContext(context.Background(), stmt.query, nil)
+}
+
+type testSQLTx struct {
+	database *testSQL
+}
+
+func (tx *testSQLTx) Commit() error {
+	tx.database.record("COMMIT")
+	return nil
+}
+
+func (tx *testSQLTx) Rollback() error {
+	tx.database.record("ROLLBACK")
+	return nil
+}
+
+type testSQLResult struct {
+	lastInsertID int64
+	count        []driver.Value
+	chanBlocked  chan string
+}
+
+func (result testSQLResult) LastInsertId() (int64, error) {
+	return result.lastInsertID, nil
+}
+
+func (result testSQLResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+type testSQLRows struct {
+	columns []string
+	values  [][]driver.Value
+	index   int
+}
+
+func (rows *testSQLRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *testSQLRows) Close() error {
+	return nil
+}
+
+func (rows *testSQLRows) Next(dest []driver.Value) error {
+	if rows.index >= len(rows.values) {
+		return io.EOF
+	}
+	copy(dest, rows.values[rows.index])
+	rows.index++
+	return nil
+}
+
+// WriteStatements 返回执行的语句，不包括查询
+func (database *testSQL) WriteStatements() []string {
+	statements := make([]string, 0)
+	for _, statement := range database.Statements() {
+		if !strings.HasPrefix(statement, "SELECT") {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+func checkStatements(t *testing.T, database *testSQL, statements ...string) {
+	t.Helper()
+	if got := database.Statements(); !reflect.DeepEqual(got, statements) {
+		t.Fatalf("statements: %q, want: %q", got, statements)
+	}
+}
+
+var errTestTransaction = errors.New("test transaction")
+
+func TestDBTransactionCommit(t *testing.T) {
+	db, database := newTestDB(t)
+	err := db.Transaction(func(tx *DB) error {
+		if !tx.InTransaction() {
+			t.Fatal("not in transaction")
+		}
+		return tx.GormDB().Exec("UPDATE a").Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatements(t, database, "BEGIN", "UPDATE a", "COMMIT")
+}
+
+func TestDBTransactionRollback(t *testing.T) {
+	db, database := newTestDB(t)
+	err := db.Transaction(func(tx *DB) error {
+		tx.GormDB().Exec("UPDATE a")
+		return errTestTransaction
+	})
+	if err != errTestTransaction {
+		t.Fatal(err)
+	}
+	checkStatements(t, database, "BEGIN", "UPDATE a", "ROLLBACK")
+}
+
+func TestDBTransactionPanic(t *testing.T) {
+	db, database := newTestDB(t)
+	func() {
+		defer func() {
+			if recover := recover(); recover != errTestTransaction {
+				t.Fatal(recover)
+			}
+		}()
+		db.Transaction(func(tx *DB) error {
+			tx.GormDB().Exec("UPDATE a")
+			panic(errTestTransaction)
+		})
+	}()
+	checkStatements(t, database, "BEGIN", "UPDATE a", "ROLLBACK")
+}
+
+func TestDBTransactionSavepoint(t *testing.T) {
+	db, database := newTestDB(t)
+	err := db.Transaction(func(tx *DB) error {
+		tx.GormDB().Exec("UPDATE a")
+		if err := tx.Transaction(func(tx *DB) error {
+			tx.GormDB().Exec("UPDATE b")
+			return tx.Transaction(func(tx *DB) error {
+				return tx.GormDB().Exec("UPDATE c").Error
+			})
+		}); err != nil {
+			return err
+		}
+		if err := tx.Transaction(func(tx *DB) error {
+			tx.GormDB().Exec("UPDATE d")
+			return errTestTransaction
+		}); err != errTestTransaction {
+			t.Fatal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatements(t, database,
+		"BEGIN",
+		"UPDATE a",
+		"SAVEPOINT gglmm_savepoint_1",
+		"UPDATE b",
+		"SAVEPOINT gglmm_savepoint_2",
+		"UPDATE c",
+		"RELEASE SAVEPOINT gglmm_savepoint_2",
+		"RELEASE SAVEPOINT gglmm_savepoint_1",
+		"SAVEPOINT gglmm_savepoint_1",
+		"UPDATE d",
+		"ROLLBACK TO SAVEPOINT gglmm_savepoint_1",
+		"COMMIT",
+	)
+}
+
+func TestDBTransactionSavepointPanic(t *testing.T) {
+	db, database := newTestDB(t)
+	func() {
+		defer func() {
+			if recover := recover(); recover != errTestTransaction {
+				t.Fatal(recover)
+			}
+		}()
+		db.Transaction(func(tx *DB) error {
+			return tx.Transaction(func(tx *DB) error {
+				panic(errTestTransaction)
+			})
+		})
+	}()
+	checkStatements(t, database,
+		"BEGIN",
+		"SAVEPOINT gglmm_savepoint_1",
+		"ROLLBACK TO SAVEPOINT gglmm_savepoint_1",
+		"ROLLBACK",
+	)
+}
+
+func setTestRows(database *testSQL, count int) {
+	rows := make([][]driver.Value, 0, count)
+	for id := 1; id <= count; id++ {
+		rows = append(rows, []driver.Value{int64(id), "n"})
+	}
+	database.SetRows([]string{"id", "name"}, rows...)
+}
+
+func TestDBPageSkipTotal(t *testing.T) {
+	db, database := newTestDB(t)
+	request := PageRequest{Pagination: Pagination{PageIndex: 2, PageSize: 2}, SkipTotal: true}
+
+	// 多取一行判断是否有下一页，返回时去掉多取的一行
+	setTestRows(database, 3)
+	list := make([]testDefaultModel, 0)
+	response := PageResponse{List: &list}
+	if err := db.Page(&response, &request); err != nil {
+		t.Fatal(err)
+	}
+	statements := database.Statements()
+	if len(statements) != 1 || !strings.HasSuffix(statements[0], "LIMIT 3 OFFSET 2") {
+		t.Fatal(statements)
+	}
+	if !response.Pagination.HasMore || len(list) != 2 || list[1].ID != 2 || response.Pagination.Total != 0 {
+		t.Fatal(response.Pagination, list)
+	}
+
+	setTestRows(database, 2)
+	list = make([]testDefaultModel, 0)
+	response = PageResponse{List: &list}
+	if err := db.Page(&response, &request); err != nil {
+		t.Fatal(err)
+	}
+	if response.Pagination.HasMore || len(list) != 2 {
+		t.Fatal(response.Pagination, list)
+	}
+}
+
+func TestDBPageTotal(t *testing.T) {
+	db, database := newTestDB(t)
+	setTestRows(database, 2)
+	request := PageRequest{Pagination: Pagination{PageIndex: 2, PageSize: 2}}
+	for _, c := range []struct {
+		total   int64
+		hasMore bool
+	}{{5, true}, {4, false}, {3, false}} {
+		database.SetCount(c.total)
+		list := make([]testDefaultModel, 0)
+		response := PageResponse{List: &list}
+		if err := db.Page(&response, &request); err != nil {
+			t.Fatal(err)
+		}
+		if response.Pagination.Total != int(c.total) || response.Pagination.HasMore != c.hasMore || len(list) != 2 {
+			t.Fatal(c, response.Pagination)
+		}
+	}
+	statements := database.Statements()
+	if len(statements) != 6 || !strings.Contains(statements[0], "count(*)") || !strings.HasSuffix(statements[1], "LIMIT 2 OFFSET 2") {
+		t.Fatal(statements)
+	}
+}
@@ -3,6 +3,7 @@ package gglmm
 import (
 	"encoding/json"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
@@ -42,3 +43,23 @@ func DecodeBody(r *http.Request, body interface{}) error {
 	}
 	return nil
 }
+
+// DecodeBodyKeys 解码请求体，并返回请求体中出现的键
+func DecodeBodyKeys(r *http.Request, body interface{}) ([]string, error) {
+	bytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, ErrRequest
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(bytes, &raw); err != nil {
+		return nil, ErrRequest
+	}
+	if err := json.Unmarshal(bytes, body); err != nil {
+		return nil, ErrRequest
+	}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -91,3 +91,22 @@ func TestDecodePageRequest(t *testing.T) {
 		t.Fatal(resultPageRequest)
 	}
 }
+
+func TestDecodeBodyKeys(t *testing.T) {
+	request, err := http.NewRequest("PATCH", "/test", bytes.NewBufferString(`{"name":"a","age":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := testFieldsModel{}
+	keys, err := DecodeBodyKeys(request, &model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || model.Name != "a" || model.Age != 2 {
+		t.Fatal(keys, model)
+	}
+	request, _ = http.NewRequest("PATCH", "/test", bytes.NewBufferString(`[1]`))
+	if _, err := DecodeBodyKeys(request, &model); err != ErrRequest {
+		t.Fatal(err)
+	}
+}
@@ -1,65 +1,262 @@
 package gglmm
 
 import (
+	"context"
+	"crypto/tls"
 	"log"
 	"net/http"
 	"net/rpc"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
 )
 
-var basePath string = ""
+// ServerOptions 服务选项
+type ServerOptions struct {
+	BasePath              string
+	DisablePanicResponser bool
+	TimeLoggerThreshold   int64 //单位：纳秒，大于0时使用TimeLogger
+	GormDB                *gorm.DB
+	ServeMux              *http.ServeMux // 为空时新建
+	RPCServer             *rpc.Server    // 未通过RegisterRPC注册的服务（如rpc.Register）转发到此，为空时不转发
+}
+
+// Server 服务实例，拥有自己的路由、注册信息、中间件设置以及数据库
+type Server struct {
+	basePath string
+
+	usePanicResponser        bool
+	middlewarePanicResponser *Middleware
+
+	useTimeLogger        bool
+	timeLoggerThreshold  int64
+	middlewareTimeLogger *Middleware
+
+	httpHandlerConfigs []*HTTPHandlerConfig
+	httpActionConfigs  []*HTTPActionConfig
+	wsHandlerConfigs   []*WSHandlerConfig
+	rpcHandlerConfigs  []*RPCHandlerConfig
+	rpcInterceptors    []*RPCInterceptor
+
+	gormDB        *gorm.DB
+	serveMux      *http.ServeMux
+	wsConns       *wsConns
+	rpcDispatcher *rpcDispatcher
+	rpcHTTP       *rpcListener
+	rpcDefault    bool // 默认实例：RegisterRPC的服务同时注册到rpc.DefaultServer，并与rpc.HandleHTTP一致注册调试页面
+	handler       http.Handler
+
+	handlerOnce sync.Once
+	rpcOnce     sync.Once
+
+	mutex        sync.Mutex
+	rpcListeners []*rpcListener
+	httpServer   *http.Server
+	closed       bool
+
+	shutdownTimeout time.Duration
+	shutdownHooks   []func()
+	shutdownOnce    sync.Once
+	shutdownErr     error
+}
+
+// NewServer 新建服务实例
+func NewServer(options ServerOptions) *Server {
+	server := &Server{
+		basePath:                 options.BasePath,
+		usePanicResponser:        !options.DisablePanicResponser,
+		middlewarePanicResponser: MiddlewarePanicResponser(),
+		httpHandlerConfigs:       make([]*HTTPHandlerConfig, 0),
+		httpActionConfigs:        make([]*HTTPActionConfig, 0),
+		wsHandlerConfigs:         make([]*WSHandlerConfig, 0),
+		rpcHandlerConfigs:        make([]*RPCHandlerConfig, 0),
+		gormDB:                   options.GormDB,
+		serveMux:                 options.ServeMux,
+		wsConns:                  newWSConns(),
+		rpcInterceptors:          make([]*RPCInterceptor, 0),
+		rpcDispatcher:            newRPCDispatcher(options.RPCServer),
+		shutdownTimeout:          DefaultShutdownTimeout,
+		shutdownHooks:            make([]func(), 0),
+	}
+	if options.TimeLoggerThreshold > 0 {
+		server.UseTimeLogger(true, options.TimeLoggerThreshold)
+	}
+	if server.serveMux == nil {
+		server.serveMux = http.NewServeMux()
+	}
+	if server.gormDB != nil {
+		RegisterGormContextCallbacks(server.gormDB)
+	}
+	server.rpcHTTP = newRPCListener(server.rpcDispatcher, nil, 0)
+	return server
+}
 
-var usePanicResponser = true
-var middlewarePanicResponser = MiddlewarePanicResponser()
+// defaultServer 包级函数使用的默认实例，注册在http.DefaultServeMux上，rpc.Register注册的服务同样可调用
+var defaultServer = newDefaultServer()
 
-var useTimeLogger = false
-var middlewareTimeLogger *Middleware = nil
+func newDefaultServer() *Server {
+	server := NewServer(ServerOptions{
+		ServeMux:  http.DefaultServeMux,
+		RPCServer: rpc.DefaultServer,
+	})
+	server.rpcDefault = true
+	return server
+}
+
+// DefaultServer 默认实例
+func DefaultServer() *Server {
+	return defaultServer
+}
 
 // BasePath 基础路径
-func BasePath(path string) {
-	basePath = path
+func (server *Server) BasePath(path string) {
+	server.basePath = path
 }
 
 // UsePanicResponser --
-func UsePanicResponser(use bool) {
-	usePanicResponser = use
+func (server *Server) UsePanicResponser(use bool) {
+	server.usePanicResponser = use
 }
 
 // UseTimeLogger --
-func UseTimeLogger(use bool, threshold int64) {
-	useTimeLogger = use
-	if useTimeLogger {
-		middlewareTimeLogger = MiddlewareTimeLogger(threshold)
+func (server *Server) UseTimeLogger(use bool, threshold int64) {
+	server.useTimeLogger = use
+	server.timeLoggerThreshold = threshold
+	if server.useTimeLogger {
+		server.middlewareTimeLogger = MiddlewareTimeLogger(threshold)
 	} else {
-		middlewareTimeLogger = nil
+		server.middlewareTimeLogger = nil
 	}
 }
 
-// ListenAndServe 监听并服务
-func ListenAndServe(address string) {
-	log.Println("listen on: " + address)
+// Handler 组装HTTP、HTTPAction、WebSocket以及RPC-over-HTTP路由并返回，不启动监听
+// 可挂载到已有服务、包裹自定义中间件或用于httptest；只组装一次，之后的注册不再生效
+func (server *Server) Handler() http.Handler {
+	server.handlerOnce.Do(func() {
+		router := mux.NewRouter()
+		server.handleHTTP(router)
+		server.handleHTTPAction(router)
+		server.handleWS(router)
+		server.serveMux.Handle("/", router)
+
+		server.registerRPC()
+		server.handler = server.serveMux
+		if !server.rpcDefault {
+			server.serveMux.Handle(rpc.DefaultRPCPath, server.rpcHTTP)
+			return
+		}
+		// 默认实例与rpc.HandleHTTP一致，http.DefaultServeMux直接使用rpc.DefaultServer并提供rpc.DefaultDebugPath
+		// Handler返回的http.Handler中rpc.DefaultRPCPath仍经过RPC拦截器，Shutdown时关闭
+		rpc.HandleHTTP()
+		server.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == rpc.DefaultRPCPath {
+				server.rpcHTTP.ServeHTTP(w, r)
+				return
+			}
+			server.serveMux.ServeHTTP(w, r)
+		})
+	})
+	return server.handler
+}
 
-	router := mux.NewRouter()
-	handleHTTP(router)
-	handleHTTPAction(router)
-	http.Handle("/", router)
+// ListenAndServe 监听并服务，收到SIGINT、SIGTERM时优雅关闭
+func (server *Server) ListenAndServe(address string) {
+	log.Println("listen on: " + address)
 
-	handleWS()
+	httpServer := &http.Server{
+		Addr:    address,
+		Handler: server.Handler(),
+	}
+	server.serve(server.listenHTTP(httpServer, httpServer.ListenAndServe))
+}
 
-	registerRPC()
-	rpc.HandleHTTP()
+// listenHTTP 记录httpServer供Shutdown关闭，已关闭时不再监听
+func (server *Server) listenHTTP(httpServer *http.Server, listen func() error) func() error {
+	return func() error {
+		server.mutex.Lock()
+		if server.closed {
+			server.mutex.Unlock()
+			return http.ErrServerClosed
+		}
+		server.httpServer = httpServer
+		server.mutex.Unlock()
+		return listen()
+	}
+}
 
-	err := http.ListenAndServe(address, nil)
+// ListenAndServeConfig 监听并服务，设置证书时使用TLS
+func (server *Server) ListenAndServeConfig(config ConfigHTTP) {
+	if config.TimeLoggerThreshold > 0 {
+		server.UseTimeLogger(true, config.TimeLoggerThreshold)
+	}
+	if config.ShutdownTimeout > 0 {
+		server.SetShutdownTimeout(time.Duration(config.ShutdownTimeout) * time.Second)
+	}
+	tlsConfig, err := config.TLSConfig()
 	if err != nil {
 		panic(err)
 	}
+
+	httpServer := &http.Server{
+		Addr:         config.Address,
+		Handler:      server.Handler(),
+		TLSConfig:    tlsConfig,
+		ReadTimeout:  time.Duration(config.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(config.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(config.IdleTimeout) * time.Second,
+	}
+	if !config.UseTLS() {
+		log.Println("listen on: " + config.Address)
+		server.serve(server.listenHTTP(httpServer, httpServer.ListenAndServe))
+		return
+	}
+	if config.DisableHTTP2 {
+		httpServer.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	log.Println("listen on(tls): " + config.Address)
+	server.serve(server.listenHTTP(httpServer, func() error {
+		return httpServer.ListenAndServeTLS(config.CertFile, config.KeyFile)
+	}))
+}
+
+// BasePath 基础路径
+func BasePath(path string) {
+	defaultServer.BasePath(path)
+}
+
+// UsePanicResponser --
+func UsePanicResponser(use bool) {
+	defaultServer.UsePanicResponser(use)
+}
+
+// UseTimeLogger --
+func UseTimeLogger(use bool, threshold int64) {
+	defaultServer.UseTimeLogger(use, threshold)
+}
+
+// Handler 组装默认实例的路由并返回，不启动监听
+func Handler() http.Handler {
+	return defaultServer.Handler()
+}
+
+// ListenAndServe 监听并服务
+func ListenAndServe(address string) {
+	defaultServer.ListenAndServe(address)
 }
 
 // ListenAndServeConfig 监听并服务
 func ListenAndServeConfig(config ConfigHTTP) {
-	if config.TimeLoggerThreshold > 0 {
-		UseTimeLogger(true, config.TimeLoggerThreshold)
-	}
-	ListenAndServe(config.Address)
+	defaultServer.ListenAndServeConfig(config)
+}
+
+// OnShutdown 注册关闭时执行的函数
+func OnShutdown(hook func()) {
+	defaultServer.OnShutdown(hook)
+}
+
+// Shutdown 关闭服务
+func Shutdown(ctx context.Context) error {
+	return defaultServer.Shutdown(ctx)
 }
@@ -20,8 +20,8 @@ func TestGGLMM(t *testing.T) {
 	HandleHTTPAction("/api/custom", CustomAction, "GET")
 
 	router := mux.NewRouter()
-	handleHTTP(router)
-	handleHTTPAction(router)
+	defaultServer.handleHTTP(router)
+	defaultServer.handleHTTPAction(router)
 
 	testResponse := httptest.NewRecorder()
 	testRequest, _ := http.NewRequest("GET", "/api/custom", nil)
@@ -40,3 +40,31 @@ func TestGGLMM(t *testing.T) {
 		t.Fatal(success)
 	}
 }
+
+func TestServers(t *testing.T) {
+	serverA := NewServer(ServerOptions{BasePath: "/a"})
+	serverA.HandleHTTPAction("/custom", CustomAction, "GET")
+	serverB := NewServer(ServerOptions{BasePath: "/b"})
+	serverB.HandleHTTPAction("/custom", CustomAction, "GET")
+
+	handlerA := serverA.Handler()
+	handlerB := serverB.Handler()
+
+	testResponse := httptest.NewRecorder()
+	testRequest, _ := http.NewRequest("GET", "/a/custom", nil)
+	handlerA.ServeHTTP(testResponse, testRequest)
+	if testResponse.Code != http.StatusOK {
+		t.Fatal(testResponse.Code)
+	}
+
+	testResponse = httptest.NewRecorder()
+	testRequest, _ = http.NewRequest("GET", "/a/custom", nil)
+	handlerB.ServeHTTP(testResponse, testRequest)
+	if testResponse.Code != http.StatusNotFound {
+		t.Fatal(testResponse.Code)
+	}
+
+	if serverA.Handler() != handlerA {
+		t.Fatal("handler rebuilt")
+	}
+}
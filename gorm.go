@@ -16,10 +16,11 @@ var (
 	ErrFilterValueType = errors.New("过滤值类型错误")
 	ErrFilterValueSize = errors.New("过滤值大小错误")
 	ErrFilterOperate   = errors.New("过滤操作错误")
+	ErrFilterField     = errors.New("过滤字段不允许")
+	ErrFilterOrder     = errors.New("排序字段不允许")
+	ErrFilterLogic     = errors.New("过滤组合错误")
 )
 
-var gormDB *gorm.DB = nil
-
 // GormOpenConfig --
 func GormOpenConfig(config ConfigDB) *gorm.DB {
 	connMaxLifetime, err := time.ParseDuration(fmt.Sprintf("%ds", config.ConnMaxLifetime))
@@ -45,6 +46,7 @@ func GormOpen(dialect string, url string, maxOpen int, maxIdle int, connMaxLifet
 	}
 
 	db.SingularTable(true)
+	RegisterGormContextCallbacks(db)
 
 	sqlDB := db.DB()
 	sqlDB.SetMaxOpenConns(maxOpen)
@@ -55,31 +57,51 @@ func GormOpen(dialect string, url string, maxOpen int, maxIdle int, connMaxLifet
 }
 
 // RegisterGormDBConfig --
-func RegisterGormDBConfig(config ConfigDB) {
-	gormDB = GormOpenConfig(config)
+func (server *Server) RegisterGormDBConfig(config ConfigDB) {
+	server.gormDB = GormOpenConfig(config)
 }
 
 // RegisterGormDB --
-func RegisterGormDB(dialect string, url string, maxOpen int, maxIdle int, connMaxLifetime time.Duration) {
-	gormDB = GormOpen(dialect, url, maxOpen, maxIdle, connMaxLifetime)
+func (server *Server) RegisterGormDB(dialect string, url string, maxOpen int, maxIdle int, connMaxLifetime time.Duration) {
+	server.gormDB = GormOpen(dialect, url, maxOpen, maxIdle, connMaxLifetime)
 }
 
 // CloseGormDB --
-func CloseGormDB() {
-	if gormDB != nil {
-		gormDB.Close()
+func (server *Server) CloseGormDB() {
+	if server.gormDB != nil {
+		server.gormDB.Close()
 	}
 }
 
 // ErrGormDBNotRegister --
 var ErrGormDBNotRegister = errors.New("请注册GromDB")
 
-// DefaultGormDB --
-func DefaultGormDB() *gorm.DB {
-	if nil == gormDB {
+// GormDB --
+func (server *Server) GormDB() *gorm.DB {
+	if nil == server.gormDB {
 		log.Fatal(ErrGormDBNotRegister)
 	}
-	return gormDB
+	return server.gormDB
+}
+
+// RegisterGormDBConfig --
+func RegisterGormDBConfig(config ConfigDB) {
+	defaultServer.RegisterGormDBConfig(config)
+}
+
+// RegisterGormDB --
+func RegisterGormDB(dialect string, url string, maxOpen int, maxIdle int, connMaxLifetime time.Duration) {
+	defaultServer.RegisterGormDB(dialect, url, maxOpen, maxIdle, connMaxLifetime)
+}
+
+// CloseGormDB --
+func CloseGormDB() {
+	defaultServer.CloseGormDB()
+}
+
+// DefaultGormDB --
+func DefaultGormDB() *gorm.DB {
+	return defaultServer.GormDB()
 }
 
 func gormPreloads(db *gorm.DB, preloads []string) *gorm.DB {
@@ -94,6 +116,12 @@ func gormFilterRequest(db *gorm.DB, filterRequest *FilterRequest) (*gorm.DB, err
 	if err != nil {
 		return nil, err
 	}
+	for _, group := range filterRequest.Groups {
+		db, err = gormFilterGroup(db, group)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if filterRequest.Order != "" {
 		db = db.Order(filterRequest.Order)
 	}
@@ -112,7 +140,7 @@ func gormFilters(db *gorm.DB, filters []*Filter) (*gorm.DB, error) {
 }
 
 func gormFilter(db *gorm.DB, filter *Filter) (*gorm.DB, error) {
-	if !filter.Check() {
+	if filter == nil || !filter.Check() {
 		return nil, ErrFilter
 	}
 	if filter.Field == FilterFieldDeleted {
@@ -126,61 +154,163 @@ func gormFilter(db *gorm.DB, filter *Filter) (*gorm.DB, error) {
 		}
 		return db, nil
 	}
+	query, values, err := filterSQL(filter)
+	if err != nil {
+		return nil, err
+	}
+	if query == "" {
+		return db, nil
+	}
+	return db.Where(query, values...), nil
+}
+
+func gormFilterGroup(db *gorm.DB, group *FilterGroup) (*gorm.DB, error) {
+	query, values, err := filterGroupSQL(group)
+	if err != nil {
+		return nil, err
+	}
+	if query == "" {
+		return db, nil
+	}
+	return db.Where(query, values...), nil
+}
+
+func filterGroupSQL(group *FilterGroup) (string, []interface{}, error) {
+	if group == nil {
+		return "", nil, ErrFilter
+	}
+	var separator string
+	switch group.Logic {
+	case "", FilterLogicAnd, FilterLogicNot:
+		separator = " and "
+	case FilterLogicOr:
+		separator = " or "
+	default:
+		return "", nil, ErrFilterLogic
+	}
+	queries := make([]string, 0)
+	values := make([]interface{}, 0)
+	for _, filter := range group.Filters {
+		if filter == nil || !filter.Check() || filter.Field == FilterFieldDeleted {
+			return "", nil, ErrFilter
+		}
+		query, filterValues, err := filterSQL(filter)
+		if err != nil {
+			return "", nil, err
+		}
+		if query != "" {
+			queries = append(queries, query)
+			values = append(values, filterValues...)
+		}
+	}
+	for _, subGroup := range group.Groups {
+		query, groupValues, err := filterGroupSQL(subGroup)
+		if err != nil {
+			return "", nil, err
+		}
+		if query != "" {
+			queries = append(queries, query)
+			values = append(values, groupValues...)
+		}
+	}
+	if len(queries) == 0 {
+		return "", nil, nil
+	}
+	query := "(" + strings.Join(queries, separator) + ")"
+	if group.Logic == FilterLogicNot {
+		query = "not " + query
+	}
+	return query, values, nil
+}
+
+func filterSQL(filter *Filter) (string, []interface{}, error) {
 	switch filter.Operate {
 	case FilterOperateEqual:
-		return db.Where(filter.Field+" = ?", filter.Value), nil
+		return filter.Field + " = ?", []interface{}{filter.Value}, nil
 	case FilterOperateNotEqual:
-		return db.Where(filter.Field+" <> ?", filter.Value), nil
+		return filter.Field + " <> ?", []interface{}{filter.Value}, nil
 	case FilterOperateGreaterThan:
-		return db.Where(filter.Field+" > ?", filter.Value), nil
+		return filter.Field + " > ?", []interface{}{filter.Value}, nil
 	case FilterOperateGreaterEqual:
-		return db.Where(filter.Field+" >= ?", filter.Value), nil
+		return filter.Field + " >= ?", []interface{}{filter.Value}, nil
 	case FilterOperateLessThan:
-		return db.Where(filter.Field+" < ?", filter.Value), nil
+		return filter.Field + " < ?", []interface{}{filter.Value}, nil
 	case FilterOperateLessEqual:
-		return db.Where(filter.Field+" <= ?", filter.Value), nil
+		return filter.Field + " <= ?", []interface{}{filter.Value}, nil
 	case FilterOperateIn:
-		return gormFilterIn(db, filter)
+		return filterInSQL(filter)
+	case FilterOperateNotIn:
+		return filterNotInSQL(filter)
 	case FilterOperateBetween:
-		return gormFilterBetween(db, filter)
+		return filterBetweenSQL(filter)
+	case FilterOperateIsNull:
+		return filter.Field + " is null", nil, nil
+	case FilterOperateIsNotNull:
+		return filter.Field + " is not null", nil, nil
 	case FilterOperateLike:
-		return gormFilterLike(db, filter)
+		return filterLikeSQL(filter, "like", "or", "%", "%")
+	case FilterOperateNotLike:
+		return filterLikeSQL(filter, "not like", "and", "%", "%")
+	case FilterOperateStartsWith:
+		return filterLikeSQL(filter, "like", "or", "", "%")
+	case FilterOperateEndsWith:
+		return filterLikeSQL(filter, "like", "or", "%", "")
 	default:
-		return nil, ErrFilterOperate
+		return "", nil, ErrFilterOperate
+	}
+}
+
+func filterInSQL(filter *Filter) (string, []interface{}, error) {
+	values, ok := filter.Value.([]interface{})
+	if !ok {
+		return "", nil, ErrFilterValueType
 	}
+	return filter.Field + " in (?)", []interface{}{values}, nil
 }
 
-func gormFilterIn(db *gorm.DB, filter *Filter) (*gorm.DB, error) {
+func filterNotInSQL(filter *Filter) (string, []interface{}, error) {
 	values, ok := filter.Value.([]interface{})
 	if !ok {
-		return nil, ErrFilterValueType
+		return "", nil, ErrFilterValueType
 	}
-	return db.Where(filter.Field+" in (?)", values), nil
+	if len(values) == 0 {
+		return "", nil, ErrFilterValueSize
+	}
+	return filter.Field + " not in (?)", []interface{}{values}, nil
 }
 
-func gormFilterBetween(db *gorm.DB, filter *Filter) (*gorm.DB, error) {
+func filterBetweenSQL(filter *Filter) (string, []interface{}, error) {
 	values, ok := filter.Value.([]interface{})
 	if !ok {
-		return nil, ErrFilterValueType
+		return "", nil, ErrFilterValueType
 	}
 	if len(values) != 2 {
-		return nil, ErrFilterValueSize
+		return "", nil, ErrFilterValueSize
 	}
 	if values[0] != nil && values[1] != nil {
-		return db.Where(filter.Field+" between ? and ?", values[0], values[1]), nil
+		return filter.Field + " between ? and ?", []interface{}{values[0], values[1]}, nil
 	} else if values[0] != nil && values[1] == nil {
-		return db.Where(filter.Field+" >= ?", values[0]), nil
+		return filter.Field + " >= ?", []interface{}{values[0]}, nil
 	} else if values[0] == nil && values[1] != nil {
-		return db.Where(filter.Field+" <= ?", values[1]), nil
+		return filter.Field + " <= ?", []interface{}{values[1]}, nil
 	} else {
-		return db, nil
+		return "", nil, nil
 	}
 }
 
-func gormFilterLike(db *gorm.DB, filter *Filter) (*gorm.DB, error) {
+// filterLikeEscape 模糊查询的转义字符
+const filterLikeEscape = `\`
+
+// filterLikeEscaper 转义值中的通配符，值按字面匹配
+var filterLikeEscaper = strings.NewReplacer(filterLikeEscape, filterLikeEscape+filterLikeEscape, "%", filterLikeEscape+"%", "_", filterLikeEscape+"_")
+
+// filterLikeSQL 多个字段、多个值（以FilterSeparator分隔）的模糊查询
+// operate: like | not like；logic: 条件之间的组合方式；prefix、suffix: 值前后的通配符
+// 值中的\、%、_转义后按字面匹配，转义字符作为参数传入，不受各数据库字符串转义规则影响
+func filterLikeSQL(filter *Filter, operate string, logic string, prefix string, suffix string) (string, []interface{}, error) {
 	stringValue, ok := filter.Value.(string)
 	if !ok {
-		return nil, ErrFilterValueType
+		return "", nil, ErrFilterValueType
 	}
 	fields := strings.Split(filter.Field, FilterSeparator)
 	values := strings.Split(stringValue, FilterSeparator)
@@ -191,14 +321,14 @@ func gormFilterLike(db *gorm.DB, filter *Filter) (*gorm.DB, error) {
 	for _, field := range fields {
 		for _, value := range values {
 			if count == 0 {
-				wheres = append(wheres, field, "like", "?")
+				wheres = append(wheres, field, operate, "? escape ?")
 			} else {
-				wheres = append(wheres, "or", field, "like", "?")
+				wheres = append(wheres, logic, field, operate, "? escape ?")
 			}
-			likes = append(likes, "%"+value+"%")
+			likes = append(likes, prefix+filterLikeEscaper.Replace(value)+suffix, filterLikeEscape)
 			count++
 		}
 	}
 	wheres = append(wheres, ")")
-	return db.Where(strings.Join(wheres, " "), likes...), nil
+	return strings.Join(wheres, " "), likes, nil
 }
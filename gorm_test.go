@@ -0,0 +1,95 @@
+package gglmm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFilterGroupSQL(t *testing.T) {
+	group := NewFilterGroup(FilterLogicOr).
+		AddFilter("a", FilterOperateEqual, 1).
+		AddGroup(NewFilterGroup(FilterLogicNot).
+			AddFilter("b", FilterOperateIn, []interface{}{2, 3}).
+			AddFilter("c", FilterOperateBetween, []interface{}{nil, nil}).
+			AddFilter("d", FilterOperateLessThan, 4))
+	query, values, err := filterGroupSQL(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "(a = ? or not (b in (?) and d < ?))" {
+		t.Fatal(query)
+	}
+	if len(values) != 3 {
+		t.Fatal(values)
+	}
+	if _, _, err := filterGroupSQL(NewFilterGroup("xor").AddFilter("a", FilterOperateEqual, 1)); err != ErrFilterLogic {
+		t.Fatal(err)
+	}
+	if _, _, err := filterGroupSQL(NewFilterGroup(FilterLogicAnd).AddFilter(FilterFieldDeleted, FilterOperateEqual, "all")); err != ErrFilter {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeFilterGroups(t *testing.T) {
+	body := `{"filters":[{"field":"a","operate":"=","value":1}],"groups":[{"logic":"or","filters":[{"field":"b","operate":"=","value":2},{"field":"c","operate":"=","value":3}]}]}`
+	filterRequest := FilterRequest{}
+	if err := json.Unmarshal([]byte(body), &filterRequest); err != nil {
+		t.Fatal(err)
+	}
+	if len(filterRequest.Filters) != 1 || len(filterRequest.Groups) != 1 {
+		t.Fatal(filterRequest)
+	}
+	query, _, err := filterGroupSQL(filterRequest.Groups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "(b = ? or c = ?)" {
+		t.Fatal(query)
+	}
+}
+
+func TestFilterSQLOperate(t *testing.T) {
+	cases := []struct {
+		filter *Filter
+		query  string
+		values []interface{}
+	}{
+		{NewFilter("a", FilterOperateIsNull, nil), "a is null", nil},
+		{NewFilter("a", FilterOperateIsNotNull, nil), "a is not null", nil},
+		{NewFilter("a", FilterOperateNotIn, []interface{}{1, 2}), "a not in (?)", []interface{}{[]interface{}{1, 2}}},
+		{NewFilter("a", FilterOperateStartsWith, "x"), "( a like ? escape ? )", []interface{}{"x%", `\`}},
+		{NewFilter("a", FilterOperateEndsWith, "x"), "( a like ? escape ? )", []interface{}{"%x", `\`}},
+		{NewFilter("a|b", FilterOperateNotLike, "x"), "( a not like ? escape ? and b not like ? escape ? )", []interface{}{"%x%", `\`, "%x%", `\`}},
+		{NewFilter("a", FilterOperateLike, "x|y"), "( a like ? escape ? or a like ? escape ? )", []interface{}{"%x%", `\`, "%y%", `\`}},
+		// 通配符、转义字符按字面匹配
+		{NewFilter("a", FilterOperateStartsWith, "10%_"), "( a like ? escape ? )", []interface{}{`10\%\_%`, `\`}},
+		{NewFilter("a", FilterOperateEndsWith, `%x\`), "( a like ? escape ? )", []interface{}{`%\%x\\`, `\`}},
+		{NewFilter("a", FilterOperateNotLike, "_"), "( a not like ? escape ? )", []interface{}{`%\_%`, `\`}},
+	}
+	for _, c := range cases {
+		if !c.filter.Check() {
+			t.Fatal(c.filter)
+		}
+		query, values, err := filterSQL(c.filter)
+		if err != nil {
+			t.Fatal(c.filter, err)
+		}
+		if query != c.query || len(values) != len(c.values) {
+			t.Fatal(query, values)
+		}
+		for i, value := range values {
+			if s, ok := value.(string); ok && s != c.values[i] {
+				t.Fatal(query, values)
+			}
+		}
+	}
+	if _, _, err := filterSQL(NewFilter("a", FilterOperateNotIn, "x")); err != ErrFilterValueType {
+		t.Fatal(err)
+	}
+	if _, _, err := filterSQL(NewFilter("a", FilterOperateNotIn, []interface{}{})); err != ErrFilterValueSize {
+		t.Fatal(err)
+	}
+	if _, _, err := filterSQL(NewFilter("a", FilterOperateStartsWith, 1)); err != ErrFilterValueType {
+		t.Fatal(err)
+	}
+}
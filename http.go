@@ -48,42 +48,44 @@ func (config *HTTPHandlerConfig) Action(params ...interface{}) *HTTPHandlerConfi
 	return config
 }
 
-var httpHandlerConfigs []*HTTPHandlerConfig = nil
-
 // HandleHTTP 注册HTTPHandler
 // path 路径
 // httpHandler 处理者
-func HandleHTTP(path string, httpHandler HTTPHandler) *HTTPHandlerConfig {
-	if httpHandlerConfigs == nil {
-		httpHandlerConfigs = make([]*HTTPHandlerConfig, 0)
-	}
+func (server *Server) HandleHTTP(path string, httpHandler HTTPHandler) *HTTPHandlerConfig {
 	config := &HTTPHandlerConfig{
 		path:        path,
 		httpHandler: httpHandler,
 	}
-	httpHandlerConfigs = append(httpHandlerConfigs, config)
+	server.httpHandlerConfigs = append(server.httpHandlerConfigs, config)
 	return config
 }
 
-func handleHTTP(router *mux.Router) {
-	if httpHandlerConfigs == nil || len(httpHandlerConfigs) == 0 {
+// HandleHTTP 注册HTTPHandler
+// path 路径
+// httpHandler 处理者
+func HandleHTTP(path string, httpHandler HTTPHandler) *HTTPHandlerConfig {
+	return defaultServer.HandleHTTP(path, httpHandler)
+}
+
+func (server *Server) handleHTTP(router *mux.Router) {
+	if len(server.httpHandlerConfigs) == 0 {
 		return
 	}
-	for _, config := range httpHandlerConfigs {
-		subrouter := router.PathPrefix(basePath).Subrouter()
+	for _, config := range server.httpHandlerConfigs {
+		subrouter := router.PathPrefix(server.basePath).Subrouter()
 		for _, middlewareAcion := range config.middlewareActions {
 			middlewares := make([]string, 0)
-			if usePanicResponser {
-				subrouter.Use(mux.MiddlewareFunc(middlewarePanicResponser.Func))
-				middlewares = append(middlewares, middlewarePanicResponser.Name)
+			if server.usePanicResponser {
+				subrouter.Use(mux.MiddlewareFunc(server.middlewarePanicResponser.Func))
+				middlewares = append(middlewares, server.middlewarePanicResponser.Name)
 			}
 			for _, middleware := range middlewareAcion.middlewares {
 				subrouter.Use(mux.MiddlewareFunc(middleware.Func))
 				middlewares = append(middlewares, middleware.Name)
 			}
-			if useTimeLogger {
-				subrouter.Use(mux.MiddlewareFunc(middlewareTimeLogger.Func))
-				middlewares = append(middlewares, middlewareTimeLogger.Name)
+			if server.useTimeLogger {
+				subrouter.Use(mux.MiddlewareFunc(server.middlewareTimeLogger.Func))
+				middlewares = append(middlewares, server.middlewareTimeLogger.Name)
 			}
 			for _, action := range middlewareAcion.actions {
 				httpAction, err := config.httpHandler.Action(action)
@@ -92,7 +94,7 @@ func handleHTTP(router *mux.Router) {
 				} else if httpAction.handlerFunc != nil {
 					path := config.path + httpAction.path
 					handleHTTPFunc(subrouter, path, httpAction.handlerFunc, httpAction.methods...)
-					logHTTP(httpAction.methods, path, middlewares)
+					server.logHTTP(httpAction.methods, path, middlewares)
 				}
 			}
 		}
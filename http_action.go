@@ -33,15 +33,10 @@ func (config *HTTPActionConfig) Middleware(middlewares ...*Middleware) {
 	config.middlewares = middlewares
 }
 
-var httpActionConfigs []*HTTPActionConfig = nil
-
 // HandleHTTPAction 注册HandlerFunc
 // path 路径
 // methods 方法
-func HandleHTTPAction(path string, handlerFunc http.HandlerFunc, methods ...string) *HTTPActionConfig {
-	if httpActionConfigs == nil {
-		httpActionConfigs = make([]*HTTPActionConfig, 0)
-	}
+func (server *Server) HandleHTTPAction(path string, handlerFunc http.HandlerFunc, methods ...string) *HTTPActionConfig {
 	if methods == nil {
 		methods = []string{"GET"}
 	}
@@ -52,30 +47,37 @@ func HandleHTTPAction(path string, handlerFunc http.HandlerFunc, methods ...stri
 			methods:     methods,
 		},
 	}
-	httpActionConfigs = append(httpActionConfigs, config)
+	server.httpActionConfigs = append(server.httpActionConfigs, config)
 	return config
 }
 
-func handleHTTPAction(router *mux.Router) {
-	if httpActionConfigs == nil || len(httpActionConfigs) == 0 {
+// HandleHTTPAction 注册HandlerFunc
+// path 路径
+// methods 方法
+func HandleHTTPAction(path string, handlerFunc http.HandlerFunc, methods ...string) *HTTPActionConfig {
+	return defaultServer.HandleHTTPAction(path, handlerFunc, methods...)
+}
+
+func (server *Server) handleHTTPAction(router *mux.Router) {
+	if len(server.httpActionConfigs) == 0 {
 		return
 	}
-	for _, config := range httpActionConfigs {
-		subrouter := router.PathPrefix(basePath).Subrouter()
+	for _, config := range server.httpActionConfigs {
+		subrouter := router.PathPrefix(server.basePath).Subrouter()
 		middlewares := make([]string, 0)
-		if usePanicResponser {
-			subrouter.Use(mux.MiddlewareFunc(MiddlewarePanicResponser().Func))
-			middlewares = append(middlewares, middlewarePanicResponser.Name)
+		if server.usePanicResponser {
+			subrouter.Use(mux.MiddlewareFunc(server.middlewarePanicResponser.Func))
+			middlewares = append(middlewares, server.middlewarePanicResponser.Name)
 		}
 		for _, middleware := range config.middlewares {
 			subrouter.Use(mux.MiddlewareFunc(middleware.Func))
 			middlewares = append(middlewares, middleware.Name)
 		}
-		if useTimeLogger {
-			subrouter.Use(mux.MiddlewareFunc(middlewareTimeLogger.Func))
-			middlewares = append(middlewares, middlewareTimeLogger.Name)
+		if server.useTimeLogger {
+			subrouter.Use(mux.MiddlewareFunc(server.middlewareTimeLogger.Func))
+			middlewares = append(middlewares, server.middlewareTimeLogger.Name)
 		}
 		handleHTTPFunc(subrouter, config.httpAction.path, config.httpAction.handlerFunc, config.httpAction.methods...)
-		logHTTP(config.httpAction.methods, config.httpAction.path, middlewares)
+		server.logHTTP(config.httpAction.methods, config.httpAction.path, middlewares)
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"net/http"
 	"reflect"
+
+	"github.com/jinzhu/gorm"
 )
 
 // Err
@@ -12,6 +14,7 @@ var (
 	ErrModelType         = errors.New("模型类型错误")
 	ErrModelCanNotDelete = errors.New("模型不可删除")
 	ErrModelCanNotUpdate = errors.New("模型不可更新")
+	ErrPageSize          = errors.New("分页大小超出限制")
 )
 
 // Action --
@@ -19,18 +22,22 @@ type Action string
 
 // Action --
 const (
-	ActionGetByID Action = "GetByID"
-	ActionFirst   Action = "First"
-	ActionAdmin   Action = "Admin"
-	ActionList    Action = "List"
-	ActionPage    Action = "Page"
-	ActionCreate  Action = "Create"
-	ActionStore   Action = "Store"
-	ActionEdit    Action = "Edit"
-	ActionUpdate  Action = "Update"
-	ActionRemove  Action = "Remove"
-	ActionRestore Action = "Resotre"
-	ActionDestory Action = "Destory"
+	ActionGetByID     Action = "GetByID"
+	ActionFirst       Action = "First"
+	ActionAdmin       Action = "Admin"
+	ActionList        Action = "List"
+	ActionPage        Action = "Page"
+	ActionCreate      Action = "Create"
+	ActionStore       Action = "Store"
+	ActionEdit        Action = "Edit"
+	ActionUpdate      Action = "Update"
+	ActionPatch       Action = "Patch"
+	ActionRemove      Action = "Remove"
+	ActionBatchStore  Action = "BatchStore"
+	ActionBatchUpdate Action = "BatchUpdate"
+	ActionBatchRemove Action = "BatchRemove"
+	ActionRestore     Action = "Resotre"
+	ActionDestory     Action = "Destory"
 )
 
 // IDRegexp ID正则表达式
@@ -43,6 +50,10 @@ var (
 	WriteActions = []Action{ActionStore, ActionUpdate}
 	// DeleteActions 删除Action
 	DeleteActions = []Action{ActionRemove, ActionRestore, ActionDestory}
+	// BatchActions 批量Action
+	BatchActions = []Action{ActionBatchStore, ActionBatchUpdate, ActionBatchRemove}
+	// AdminActions 管理Action
+	AdminActions = []Action{ActionCreate, ActionEdit, ActionAdmin}
 )
 
 // FilterFunc 过滤函数
@@ -54,6 +65,9 @@ type BeforeCreateFunc func(interface{}, *http.Request) (interface{}, error)
 // BeforeUpdateFunc 更新前调用
 type BeforeUpdateFunc func(interface{}, *http.Request) (interface{}, error)
 
+// BeforePatchFunc 部分更新前调用，参数为原记录以及待更新的列
+type BeforePatchFunc func(interface{}, map[string]interface{}, *http.Request) (map[string]interface{}, error)
+
 // BeforeDeleteFunc 删除前调用
 type BeforeDeleteFunc func(interface{}, *http.Request) (interface{}, error)
 
@@ -63,27 +77,76 @@ type HTTPService struct {
 	modelType reflect.Type
 	keys      [2]string
 
+	maxPageSize       int
+	rejectMaxPageSize bool
+
 	filterFunc       FilterFunc
 	beforeCreateFunc BeforeCreateFunc
 	beforeUpdateFunc BeforeUpdateFunc
+	beforePatchFunc  BeforePatchFunc
 	beforeDeleteFunc BeforeDeleteFunc
 }
 
-// NewHTTPService 新建HTTP服务
+// NewHTTPService 新建HTTP服务，使用默认实例的数据库
 func NewHTTPService(model interface{}, keys [2]string) *HTTPService {
+	return defaultServer.NewHTTPService(model, keys)
+}
+
+// NewHTTPService 新建HTTP服务，使用实例的数据库
+func (server *Server) NewHTTPService(model interface{}, keys [2]string) *HTTPService {
 	return &HTTPService{
-		gglmmDB:   NewDB(),
+		gglmmDB:   server.NewDB(),
 		modelType: reflect.TypeOf(model),
 		keys:      keys,
 	}
 }
 
+// db 使用请求context的DB，请求取消时中止查询
+func (service *HTTPService) db(r *http.Request) *DB {
+	return service.gglmmDB.WithContext(r.Context())
+}
+
+// SetMaxPageSize 设置最大分页大小，0为不限制
+// reject为true时超出返回ErrPageSize，否则按最大分页大小查询
+func (service *HTTPService) SetMaxPageSize(maxPageSize int, reject bool) *HTTPService {
+	service.maxPageSize = maxPageSize
+	service.rejectMaxPageSize = reject
+	return service
+}
+
+func (service *HTTPService) checkPageSize(pageRequest *PageRequest) error {
+	return checkPageSize(pageRequest, service.maxPageSize, service.rejectMaxPageSize)
+}
+
+// checkPageSize 检查分页大小，maxPageSize为0时不限制
+func checkPageSize(pageRequest *PageRequest, maxPageSize int, reject bool) error {
+	if maxPageSize <= 0 || pageRequest.PageSize <= maxPageSize {
+		return nil
+	}
+	if reject {
+		return ErrPageSize
+	}
+	pageRequest.PageSize = maxPageSize
+	return nil
+}
+
 // HandleFilterFunc 设置过滤参数函数
 func (service *HTTPService) HandleFilterFunc(handler FilterFunc) *HTTPService {
 	service.filterFunc = handler
 	return service
 }
 
+// checkFilterRequest 检查客户端的过滤请求，在filterFunc追加服务端过滤条件前调用
+func (service *HTTPService) checkFilterRequest(filterRequest *FilterRequest) error {
+	return service.gglmmDB.ModelFields(reflect.New(service.modelType).Interface()).CheckFilterRequest(filterRequest)
+}
+
+// HandleModelFields 设置可过滤、可排序、可模糊查询列
+func (service *HTTPService) HandleModelFields(fields *ModelFields) *HTTPService {
+	service.gglmmDB.RegisterModelFields(reflect.New(service.modelType).Interface(), fields)
+	return service
+}
+
 // HandleBeforeCreateFunc 设置保存前执行函数
 func (service *HTTPService) HandleBeforeCreateFunc(handler BeforeCreateFunc) *HTTPService {
 	service.beforeCreateFunc = handler
@@ -96,6 +159,12 @@ func (service *HTTPService) HandleBeforeUpdateFunc(handler BeforeUpdateFunc) *HT
 	return service
 }
 
+// HandleBeforePatchFunc 设置部分更新前执行函数
+func (service *HTTPService) HandleBeforePatchFunc(handler BeforePatchFunc) *HTTPService {
+	service.beforePatchFunc = handler
+	return service
+}
+
 // HandleBeforeDeleteFunc 设置更新前执行函数
 func (service *HTTPService) HandleBeforeDeleteFunc(handler BeforeDeleteFunc) *HTTPService {
 	service.beforeDeleteFunc = handler
@@ -124,6 +193,18 @@ func (service *HTTPService) Action(action Action) (*HTTPAction, error) {
 		path = "/page"
 		handlerFunc = service.Page
 		methods = []string{"POST"}
+	case ActionAdmin:
+		path = "/admin"
+		handlerFunc = service.Admin
+		methods = []string{"POST"}
+	case ActionCreate:
+		path = "/create"
+		handlerFunc = service.Create
+		methods = []string{"GET"}
+	case ActionEdit:
+		path = "/" + IDRegexp + "/edit"
+		handlerFunc = service.Edit
+		methods = []string{"GET"}
 	case ActionStore:
 		handlerFunc = service.Store
 		methods = []string{"POST"}
@@ -131,6 +212,22 @@ func (service *HTTPService) Action(action Action) (*HTTPAction, error) {
 		path = "/" + IDRegexp
 		handlerFunc = service.Update
 		methods = []string{"PUT", "POST"}
+	case ActionPatch:
+		path = "/" + IDRegexp
+		handlerFunc = service.Patch
+		methods = []string{"PATCH"}
+	case ActionBatchStore:
+		path = "/batch"
+		handlerFunc = service.BatchStore
+		methods = []string{"POST"}
+	case ActionBatchUpdate:
+		path = "/batch"
+		handlerFunc = service.BatchUpdate
+		methods = []string{"PUT"}
+	case ActionBatchRemove:
+		path = "/batch/remove"
+		handlerFunc = service.BatchRemove
+		methods = []string{"DELETE"}
 	case ActionRemove:
 		path = "/" + IDRegexp + "/remove"
 		handlerFunc = service.Remove
@@ -158,7 +255,7 @@ func (service *HTTPService) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	model := reflect.New(service.modelType).Interface()
-	if err := service.gglmmDB.First(model, idRequest); err != nil {
+	if err := service.db(r).First(model, idRequest); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
@@ -174,11 +271,15 @@ func (service *HTTPService) First(w http.ResponseWriter, r *http.Request) {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
+	if err := service.checkFilterRequest(&filterRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
 	if service.filterFunc != nil {
 		filterRequest.Filters = service.filterFunc(filterRequest.Filters, r)
 	}
 	model := reflect.New(service.modelType).Interface()
-	if err := service.gglmmDB.First(model, filterRequest); err != nil {
+	if err := service.db(r).firstFilter(model, &filterRequest); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
@@ -194,11 +295,15 @@ func (service *HTTPService) List(w http.ResponseWriter, r *http.Request) {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
+	if err := service.checkFilterRequest(&filterRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
 	if service.filterFunc != nil {
 		filterRequest.Filters = service.filterFunc(filterRequest.Filters, r)
 	}
 	entities := reflect.New(reflect.SliceOf(service.modelType)).Interface()
-	if err := service.gglmmDB.List(entities, &filterRequest); err != nil {
+	if err := service.db(r).list(entities, &filterRequest); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
@@ -214,21 +319,80 @@ func (service *HTTPService) Page(w http.ResponseWriter, r *http.Request) {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
+	service.page(w, r, &pageRequest)
+}
+
+// Admin 分页，包括已软删除的记录
+func (service *HTTPService) Admin(w http.ResponseWriter, r *http.Request) {
+	pageRequest := PageRequest{}
+	if err := DecodeBody(r, &pageRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	filters := []*Filter{NewFilter(FilterFieldDeleted, FilterOperateEqual, FilterValueAll.Value)}
+	pageRequest.Filters = append(filters, pageRequest.Filters...)
+	service.page(w, r, &pageRequest)
+}
+
+func (service *HTTPService) page(w http.ResponseWriter, r *http.Request, pageRequest *PageRequest) {
+	if err := service.checkPageSize(pageRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	if err := service.checkFilterRequest(&pageRequest.FilterRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
 	if service.filterFunc != nil {
 		pageRequest.Filters = service.filterFunc(pageRequest.Filters, r)
 	}
 	pageResponse := &PageResponse{}
 	pageResponse.List = reflect.New(reflect.SliceOf(service.modelType)).Interface()
-	if err := service.gglmmDB.Page(pageResponse, &pageRequest); err != nil {
+	if err := service.db(r).page(pageResponse, pageRequest); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
+	if pageResponse.Cursor != nil {
+		OkResponse().
+			AddData(service.keys[1], pageResponse.List).
+			AddData("cursor", pageResponse.Cursor).
+			JSON(w)
+		return
+	}
 	OkResponse().
 		AddData(service.keys[1], pageResponse.List).
 		AddData("pagination", pageResponse.Pagination).
 		JSON(w)
 }
 
+// Create 新建表单：默认值以及字段元数据
+func (service *HTTPService) Create(w http.ResponseWriter, r *http.Request) {
+	model := reflect.New(service.modelType).Interface()
+	SetDefaultValues(model)
+	OkResponse().
+		AddData(service.keys[0], model).
+		AddData("fields", service.gglmmDB.ModelFields(model).Fields()).
+		JSON(w)
+}
+
+// Edit 编辑表单：记录以及字段元数据，与Admin一致包括已软删除的记录
+func (service *HTTPService) Edit(w http.ResponseWriter, r *http.Request) {
+	idRequest := IDRequest{}
+	if err := DecodeIDRequest(r, &idRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	model := reflect.New(service.modelType).Interface()
+	if err := service.db(r).Unscoped().First(model, idRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	OkResponse().
+		AddData(service.keys[0], model).
+		AddData("fields", service.gglmmDB.ModelFields(model).Fields()).
+		JSON(w)
+}
+
 // Store 保存
 func (service *HTTPService) Store(w http.ResponseWriter, r *http.Request) {
 	model := reflect.New(service.modelType).Interface()
@@ -244,7 +408,7 @@ func (service *HTTPService) Store(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	if err := service.gglmmDB.Create(model); err != nil {
+	if err := service.db(r).Create(model); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
@@ -273,7 +437,66 @@ func (service *HTTPService) Update(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	if err = service.gglmmDB.Update(model); err != nil {
+	if err = service.db(r).Update(model); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	OkResponse().
+		AddData(service.keys[0], model).
+		JSON(w)
+}
+
+// Patch 更新部分字段，不在WriteActions中，需单独注册ActionPatch
+// 未设置部分更新前函数时执行更新前函数
+func (service *HTTPService) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := PathVarID(r)
+	if err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	gglmmDB := service.db(r)
+	body := reflect.New(service.modelType).Interface()
+	keys, err := DecodeBodyKeys(r, body)
+	if err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	modelFields := service.gglmmDB.ModelFields(body)
+	bodyScope := &gorm.Scope{Value: body}
+	fields := make(map[string]interface{})
+	for _, key := range keys {
+		column, err := modelFields.CheckWrite(key)
+		if err != nil {
+			FailResponse(NewErrFileLine(err)).JSON(w)
+			return
+		}
+		field, _ := bodyScope.FieldByName(column)
+		fields[column] = field.Field.Interface()
+	}
+	model := reflect.New(service.modelType).Interface()
+	if service.beforePatchFunc != nil || service.beforeUpdateFunc != nil {
+		if err := gglmmDB.First(model, id); err != nil {
+			FailResponse(NewErrFileLine(err)).JSON(w)
+			return
+		}
+	} else {
+		SetPrimaryKeyValue(model, id)
+	}
+	if service.beforePatchFunc != nil {
+		fields, err = service.beforePatchFunc(model, fields, r)
+		if err != nil {
+			FailResponse(NewErrFileLine(err)).JSON(w)
+			return
+		}
+	} else if service.beforeUpdateFunc != nil {
+		// 未设置部分更新前函数时，合并部分更新后使用更新前函数
+		model, fields, err = service.patchBeforeUpdate(model, fields, r)
+		if err != nil {
+			FailResponse(NewErrFileLine(err)).JSON(w)
+			return
+		}
+	}
+	if err = gglmmDB.Updates(model, fields); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
@@ -282,6 +505,36 @@ func (service *HTTPService) Update(w http.ResponseWriter, r *http.Request) {
 		JSON(w)
 }
 
+// patchBeforeUpdate 将部分更新合并到原记录后执行更新前函数，返回更新前函数修改后的属性
+func (service *HTTPService) patchBeforeUpdate(model interface{}, fields map[string]interface{}, r *http.Request) (interface{}, map[string]interface{}, error) {
+	scope := &gorm.Scope{Value: model}
+	for column, value := range fields {
+		field, _ := scope.FieldByName(column)
+		if err := field.Set(value); err != nil {
+			return nil, nil, err
+		}
+	}
+	merged := make(map[string]interface{})
+	for _, field := range scope.Fields() {
+		merged[field.DBName] = field.Field.Interface()
+	}
+	model, err := service.beforeUpdateFunc(model, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	updated := make(map[string]interface{})
+	for _, field := range (&gorm.Scope{Value: model}).Fields() {
+		if field.IsPrimaryKey || field.IsIgnored || field.Relationship != nil {
+			continue
+		}
+		value := field.Field.Interface()
+		if _, ok := fields[field.DBName]; ok || !reflect.DeepEqual(value, merged[field.DBName]) {
+			updated[field.DBName] = value
+		}
+	}
+	return model, updated, nil
+}
+
 // Remove 软删除
 func (service *HTTPService) Remove(w http.ResponseWriter, r *http.Request) {
 	id, err := PathVarID(r)
@@ -289,9 +542,10 @@ func (service *HTTPService) Remove(w http.ResponseWriter, r *http.Request) {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
+	gglmmDB := service.db(r)
 	model := reflect.New(service.modelType).Interface()
 	if service.beforeDeleteFunc != nil {
-		if err := service.gglmmDB.First(model, id); err != nil {
+		if err := gglmmDB.First(model, id); err != nil {
 			FailResponse(NewErrFileLine(err)).JSON(w)
 			return
 		}
@@ -302,7 +556,7 @@ func (service *HTTPService) Remove(w http.ResponseWriter, r *http.Request) {
 	} else {
 		SetPrimaryKeyValue(model, id)
 	}
-	if err = service.gglmmDB.Remove(model); err != nil {
+	if err = gglmmDB.Remove(model); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
@@ -320,7 +574,7 @@ func (service *HTTPService) Restore(w http.ResponseWriter, r *http.Request) {
 	}
 	model := reflect.New(service.modelType).Interface()
 	SetPrimaryKeyValue(model, id)
-	if err = service.gglmmDB.Restore(model); err != nil {
+	if err = service.db(r).Restore(model); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
@@ -336,9 +590,10 @@ func (service *HTTPService) Destory(w http.ResponseWriter, r *http.Request) {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
+	gglmmDB := service.db(r)
 	model := reflect.New(service.modelType).Interface()
 	if service.beforeDeleteFunc != nil {
-		if err := service.gglmmDB.First(model, id); err != nil {
+		if err := gglmmDB.First(model, id); err != nil {
 			FailResponse(NewErrFileLine(err)).JSON(w)
 			return
 		}
@@ -349,7 +604,7 @@ func (service *HTTPService) Destory(w http.ResponseWriter, r *http.Request) {
 	} else {
 		SetPrimaryKeyValue(model, id)
 	}
-	if err = service.gglmmDB.Destroy(model); err != nil {
+	if err = gglmmDB.Destroy(model); err != nil {
 		FailResponse(NewErrFileLine(err)).JSON(w)
 		return
 	}
@@ -0,0 +1,191 @@
+package gglmm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// BatchRequest 批量请求
+// Atomic为true时全部成功或全部失败，否则逐项执行并返回每项结果
+type BatchRequest struct {
+	Atomic  bool            `json:"atomic"`
+	Records json.RawMessage `json:"records"`
+	IDs     []uint64        `json:"ids"`
+}
+
+// BatchResult 批量操作单项结果
+type BatchResult struct {
+	Index  int         `json:"index"`
+	Record interface{} `json:"record,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// ErrBatch 批量操作失败
+type ErrBatch struct {
+	Index int
+	Err   error
+}
+
+func (err ErrBatch) Error() string {
+	return fmt.Sprintf("index: %d; %s", err.Index, err.Err.Error())
+}
+
+// batch 在同一事务中逐项执行，非atomic时每项使用保存点
+func (service *HTTPService) batch(r *http.Request, count int, atomic bool, itemFunc func(*DB, int) (interface{}, error)) ([]*BatchResult, error) {
+	results := make([]*BatchResult, 0, count)
+	err := service.db(r).Transaction(func(tx *DB) error {
+		for index := 0; index < count; index++ {
+			if atomic {
+				record, err := itemFunc(tx, index)
+				if err != nil {
+					return ErrBatch{Index: index, Err: err}
+				}
+				results = append(results, &BatchResult{Index: index, Record: record})
+				continue
+			}
+			var record interface{}
+			err := tx.Transaction(func(itemTx *DB) error {
+				var err error
+				record, err = itemFunc(itemTx, index)
+				return err
+			})
+			if err != nil {
+				results = append(results, &BatchResult{Index: index, Error: err.Error()})
+			} else {
+				results = append(results, &BatchResult{Index: index, Record: record})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (service *HTTPService) decodeBatchRecords(batchRequest *BatchRequest) (reflect.Value, error) {
+	records := reflect.New(reflect.SliceOf(reflect.PtrTo(service.modelType)))
+	if len(batchRequest.Records) == 0 {
+		return reflect.Value{}, ErrRequest
+	}
+	if err := json.Unmarshal(batchRequest.Records, records.Interface()); err != nil {
+		return reflect.Value{}, ErrRequest
+	}
+	return records.Elem(), nil
+}
+
+// BatchStore 批量保存
+func (service *HTTPService) BatchStore(w http.ResponseWriter, r *http.Request) {
+	batchRequest := BatchRequest{}
+	if err := DecodeBody(r, &batchRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	records, err := service.decodeBatchRecords(&batchRequest)
+	if err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	results, err := service.batch(r, records.Len(), batchRequest.Atomic, func(txDB *DB, index int) (interface{}, error) {
+		record := records.Index(index)
+		if record.IsNil() {
+			return nil, ErrRequest
+		}
+		model := record.Interface()
+		if service.beforeCreateFunc != nil {
+			var err error
+			if model, err = service.beforeCreateFunc(model, r); err != nil {
+				return nil, err
+			}
+		}
+		if err := txDB.Create(model); err != nil {
+			return nil, err
+		}
+		return model, nil
+	})
+	if err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	OkResponse().
+		AddData("results", results).
+		JSON(w)
+}
+
+// BatchUpdate 批量更新整体，记录需包含主键
+func (service *HTTPService) BatchUpdate(w http.ResponseWriter, r *http.Request) {
+	batchRequest := BatchRequest{}
+	if err := DecodeBody(r, &batchRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	records, err := service.decodeBatchRecords(&batchRequest)
+	if err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	results, err := service.batch(r, records.Len(), batchRequest.Atomic, func(txDB *DB, index int) (interface{}, error) {
+		record := records.Index(index)
+		if record.IsNil() {
+			return nil, ErrRequest
+		}
+		model := record.Interface()
+		if service.beforeUpdateFunc != nil {
+			var err error
+			if model, err = service.beforeUpdateFunc(model, r); err != nil {
+				return nil, err
+			}
+		}
+		if err := txDB.Update(model); err != nil {
+			return nil, err
+		}
+		return model, nil
+	})
+	if err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	OkResponse().
+		AddData("results", results).
+		JSON(w)
+}
+
+// BatchRemove 批量软删除
+func (service *HTTPService) BatchRemove(w http.ResponseWriter, r *http.Request) {
+	batchRequest := BatchRequest{}
+	if err := DecodeBody(r, &batchRequest); err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	if len(batchRequest.IDs) == 0 {
+		FailResponse(NewErrFileLine(ErrRequest)).JSON(w)
+		return
+	}
+	results, err := service.batch(r, len(batchRequest.IDs), batchRequest.Atomic, func(txDB *DB, index int) (interface{}, error) {
+		model := reflect.New(service.modelType).Interface()
+		id := batchRequest.IDs[index]
+		if service.beforeDeleteFunc != nil {
+			if err := txDB.First(model, id); err != nil {
+				return nil, err
+			}
+			if _, err := service.beforeDeleteFunc(model, r); err != nil {
+				return nil, err
+			}
+		} else {
+			SetPrimaryKeyValue(model, id)
+		}
+		if err := txDB.Remove(model); err != nil {
+			return nil, err
+		}
+		return model, nil
+	})
+	if err != nil {
+		FailResponse(NewErrFileLine(err)).JSON(w)
+		return
+	}
+	OkResponse().
+		AddData("results", results).
+		JSON(w)
+}
@@ -0,0 +1,185 @@
+package gglmm
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type testBatchResponse struct {
+	StatusCode   int    `json:"statusCode"`
+	ErrorMessage string `json:"errorMessage"`
+	Data         struct {
+		Results []struct {
+			Index  int              `json:"index"`
+			Record testDefaultModel `json:"record"`
+			Error  string           `json:"error"`
+		} `json:"results"`
+	} `json:"data"`
+}
+
+func serveTestBatch(t *testing.T, router *mux.Router, method string, path string, body string) (int, *testBatchResponse) {
+	testResponse := httptest.NewRecorder()
+	testRequest, _ := http.NewRequest(method, path, strings.NewReader(body))
+	router.ServeHTTP(testResponse, testRequest)
+	if testResponse.Code == http.StatusMethodNotAllowed {
+		return testResponse.Code, nil
+	}
+	response := testBatchResponse{}
+	if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+		t.Fatal(testResponse.Body.String())
+	}
+	return testResponse.Code, &response
+}
+
+func newTestBatchService(t *testing.T) (*HTTPService, *mux.Router, *testSQL) {
+	db, database := newTestDB(t)
+	service, router := newTestService(t, db, BatchActions...)
+	return service, router, database
+}
+
+func TestHTTPServiceBatchStoreAtomic(t *testing.T) {
+	_, router, database := newTestBatchService(t)
+
+	code, response := serveTestBatch(t, router, "POST", "/test/batch", `{"atomic":true,"records":[{"name":"a"},{"name":"b"}]}`)
+	if code != http.StatusOK || len(response.Data.Results) != 2 {
+		t.Fatal(code, response)
+	}
+	for index, result := range response.Data.Results {
+		if result.Index != index || result.Record.ID != uint64(index+1) || result.Error != "" {
+			t.Fatal(result)
+		}
+	}
+	statements := database.WriteStatements()
+	if len(statements) != 4 || statements[0] != "BEGIN" || !strings.HasPrefix(statements[1], "INSERT") || statements[3] != "COMMIT" {
+		t.Fatal(statements)
+	}
+
+	_, router, database = newTestBatchService(t)
+	code, response = serveTestBatch(t, router, "POST", "/test/batch", `{"atomic":true,"records":[{"name":"a"},null]}`)
+	if code == http.StatusOK || !strings.Contains(response.ErrorMessage, ErrBatch{Index: 1, Err: ErrRequest}.Error()) {
+		t.Fatal(code, response)
+	}
+	statements = database.WriteStatements()
+	if len(statements) != 3 || statements[0] != "BEGIN" || statements[2] != "ROLLBACK" {
+		t.Fatal(statements)
+	}
+}
+
+func TestHTTPServiceBatchStore(t *testing.T) {
+	service, router, database := newTestBatchService(t)
+	errName := errors.New("name")
+	service.HandleBeforeCreateFunc(func(model interface{}, r *http.Request) (interface{}, error) {
+		if model.(*testDefaultModel).Name == "" {
+			return nil, errName
+		}
+		return model, nil
+	})
+
+	code, response := serveTestBatch(t, router, "POST", "/test/batch", `{"records":[{"name":"a"},null,{}]}`)
+	if code != http.StatusOK || len(response.Data.Results) != 3 {
+		t.Fatal(code, response)
+	}
+	results := response.Data.Results
+	if results[0].Error != "" || results[0].Record.ID != 1 || results[0].Record.Name != "a" {
+		t.Fatal(results[0])
+	}
+	if results[1].Index != 1 || results[1].Error != ErrRequest.Error() {
+		t.Fatal(results[1])
+	}
+	if results[2].Index != 2 || results[2].Error != errName.Error() {
+		t.Fatal(results[2])
+	}
+	statements := database.WriteStatements()
+	if len(statements) != 9 || !strings.HasPrefix(statements[2], "INSERT") {
+		t.Fatal(statements)
+	}
+	statements = append(statements[:2], statements[3:]...)
+	if !reflect.DeepEqual(statements, []string{
+		"BEGIN",
+		"SAVEPOINT gglmm_savepoint_1",
+		"RELEASE SAVEPOINT gglmm_savepoint_1",
+		"SAVEPOINT gglmm_savepoint_1",
+		"ROLLBACK TO SAVEPOINT gglmm_savepoint_1",
+		"SAVEPOINT gglmm_savepoint_1",
+		"ROLLBACK TO SAVEPOINT gglmm_savepoint_1",
+		"COMMIT",
+	}) {
+		t.Fatal(statements)
+	}
+}
+
+func TestHTTPServiceBatchUpdate(t *testing.T) {
+	_, router, database := newTestBatchService(t)
+	database.SetRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	code, response := serveTestBatch(t, router, "PUT", "/test/batch", `{"records":[{"id":1,"name":"a"},{"name":"b"},null]}`)
+	if code != http.StatusOK || len(response.Data.Results) != 3 {
+		t.Fatal(code, response)
+	}
+	results := response.Data.Results
+	if results[0].Error != "" || results[0].Record.ID != 1 || results[0].Record.Name != "a" {
+		t.Fatal(results[0])
+	}
+	if results[1].Error != ErrUpdateID.Error() || results[2].Error != ErrRequest.Error() {
+		t.Fatal(results)
+	}
+
+	code, response = serveTestBatch(t, router, "PUT", "/test/batch", `{"atomic":true,"records":[{"id":1,"name":"a"},null]}`)
+	if code == http.StatusOK || !strings.Contains(response.ErrorMessage, ErrBatch{Index: 1, Err: ErrRequest}.Error()) {
+		t.Fatal(code, response)
+	}
+	if statements := database.Statements(); statements[len(statements)-1] != "ROLLBACK" {
+		t.Fatal(statements)
+	}
+}
+
+func TestHTTPServiceBatchRemove(t *testing.T) {
+	_, router, database := newTestBatchService(t)
+	database.SetRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	code, response := serveTestBatch(t, router, "DELETE", "/test/batch/remove", `{"atomic":true,"ids":[1,2]}`)
+	if code != http.StatusOK || len(response.Data.Results) != 2 {
+		t.Fatal(code, response)
+	}
+	deletes := 0
+	for _, statement := range database.Statements() {
+		if strings.HasPrefix(statement, "UPDATE") && strings.Contains(statement, "deleted_at") {
+			deletes++
+		}
+	}
+	if deletes != 2 {
+		t.Fatal(database.Statements())
+	}
+
+	code, response = serveTestBatch(t, router, "DELETE", "/test/batch/remove", `{"ids":[]}`)
+	if code == http.StatusOK || !strings.Contains(response.ErrorMessage, ErrRequest.Error()) {
+		t.Fatal(code, response)
+	}
+}
+
+func TestHTTPServiceBatchRoutes(t *testing.T) {
+	_, router, database := newTestBatchService(t)
+	database.SetRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	if code, _ := serveTestBatch(t, router, "GET", "/test/batch", ""); code != http.StatusMethodNotAllowed {
+		t.Fatal(code)
+	}
+	if code, _ := serveTestBatch(t, router, "POST", "/test/batch/remove", ""); code != http.StatusMethodNotAllowed {
+		t.Fatal(code)
+	}
+	// POST /batch新建、PUT /batch更新
+	serveTestBatch(t, router, "POST", "/test/batch", `{"records":[{"name":"a"}]}`)
+	serveTestBatch(t, router, "PUT", "/test/batch", `{"records":[{"id":1,"name":"a"}]}`)
+	statements := database.WriteStatements()
+	if len(statements) != 10 || !strings.HasPrefix(statements[2], "INSERT") || !strings.HasPrefix(statements[7], "UPDATE") || statements[8] != "RELEASE SAVEPOINT gglmm_savepoint_1" {
+		t.Fatal(statements)
+	}
+}
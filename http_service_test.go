@@ -0,0 +1,258 @@
+package gglmm
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHTTPServiceMaxPageSize(t *testing.T) {
+	service := (&HTTPService{}).SetMaxPageSize(100, false)
+	pageRequest := PageRequest{Pagination: Pagination{PageSize: 1000000}}
+	if err := service.checkPageSize(&pageRequest); err != nil {
+		t.Fatal(err)
+	}
+	if pageRequest.PageSize != 100 {
+		t.Fatal(pageRequest.PageSize)
+	}
+	service.SetMaxPageSize(100, true)
+	pageRequest.PageSize = 101
+	if err := service.checkPageSize(&pageRequest); err != ErrPageSize {
+		t.Fatal(err)
+	}
+	pageRequest.PageSize = 100
+	if err := service.checkPageSize(&pageRequest); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPServiceCreate(t *testing.T) {
+	_, router := newTestService(t, &DB{modelFields: make(map[reflect.Type]*ModelFields)}, ActionCreate)
+	testResponse := httptest.NewRecorder()
+	testRequest, _ := http.NewRequest("GET", "/test/create", nil)
+	router.ServeHTTP(testResponse, testRequest)
+
+	response := struct {
+		Data struct {
+			Record testDefaultModel `json:"record"`
+			Fields []*ModelField    `json:"fields"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data.Record.Status != "valid" {
+		t.Fatal(testResponse.Body.String())
+	}
+	checkTestDefaultModelFields(t, response.Data.Fields)
+}
+
+func checkTestDefaultModelFields(t *testing.T, fields []*ModelField) {
+	t.Helper()
+	keys := make([]string, 0, len(fields))
+	fieldMap := make(map[string]*ModelField)
+	for _, field := range fields {
+		keys = append(keys, field.Key)
+		fieldMap[field.Key] = field
+	}
+	if !reflect.DeepEqual(keys, []string{"id", "createdAt", "updatedAt", "deletedAt", "status", "count", "rate", "name"}) {
+		t.Fatal(keys)
+	}
+	if field := fieldMap["id"]; field.Column != "id" || field.Type != ModelFieldUint || field.Writable {
+		t.Fatal(field)
+	}
+	if field := fieldMap["deletedAt"]; field.Column != "deleted_at" || field.Type != ModelFieldTime || !field.Nullable || field.Writable {
+		t.Fatal(field)
+	}
+	if field := fieldMap["status"]; field.Type != ModelFieldString || field.Default != "valid" || !field.Likeable || !field.Writable {
+		t.Fatal(field)
+	}
+	if field := fieldMap["rate"]; field.Type != ModelFieldFloat || !field.Nullable || field.Default != 0.5 || field.Likeable {
+		t.Fatal(field)
+	}
+}
+
+// newTestService 新建使用db的testDefaultModel服务，actions注册在/test下
+func newTestService(t *testing.T, db *DB, actions ...Action) (*HTTPService, *mux.Router) {
+	service := &HTTPService{
+		gglmmDB:   db,
+		modelType: reflect.TypeOf(testDefaultModel{}),
+		keys:      [2]string{"record", "records"},
+	}
+	router := mux.NewRouter()
+	for _, action := range actions {
+		httpAction, err := service.Action(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		router.HandleFunc("/test"+httpAction.path, httpAction.handlerFunc).Methods(httpAction.methods...)
+	}
+	return service, router
+}
+
+func TestHTTPServiceAdmin(t *testing.T) {
+	db, database := newTestDB(t)
+	_, router := newTestService(t, db, ActionPage, ActionAdmin)
+	for path, unscoped := range map[string]bool{"/test/page": false, "/test/admin": true} {
+		testResponse := httptest.NewRecorder()
+		testRequest, _ := http.NewRequest("POST", path, strings.NewReader(`{"skipTotal":true}`))
+		router.ServeHTTP(testResponse, testRequest)
+		if testResponse.Code != http.StatusOK {
+			t.Fatal(path, testResponse.Body.String())
+		}
+		statements := database.Statements()
+		if statement := statements[len(statements)-1]; strings.Contains(statement, "deleted_at") == unscoped {
+			t.Fatal(path, statement)
+		}
+	}
+}
+
+func TestHTTPServiceEdit(t *testing.T) {
+	db, database := newTestDB(t)
+	database.SetRows([]string{"id", "name", "deleted_at"}, []driver.Value{int64(1), "a", time.Now()})
+	_, router := newTestService(t, db, ActionEdit)
+	testResponse := httptest.NewRecorder()
+	testRequest, _ := http.NewRequest("GET", "/test/1/edit", nil)
+	router.ServeHTTP(testResponse, testRequest)
+
+	response := struct {
+		Data struct {
+			Record testDefaultModel `json:"record"`
+			Fields []*ModelField    `json:"fields"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data.Record.ID != 1 || response.Data.Record.Name != "a" || response.Data.Record.DeletedAt == nil {
+		t.Fatal(testResponse.Body.String())
+	}
+	checkTestDefaultModelFields(t, response.Data.Fields)
+	// 已软删除的记录可编辑
+	statements := database.Statements()
+	if len(statements) != 1 || strings.Contains(statements[0], "deleted_at") {
+		t.Fatal(statements)
+	}
+}
+
+func TestHTTPServiceServerFilter(t *testing.T) {
+	db, database := newTestDB(t)
+	service, router := newTestService(t, db, ActionFirst, ActionList, ActionPage)
+	service.HandleModelFields(NewModelFields(testDefaultModel{}).Filters("name"))
+	service.HandleFilterFunc(func(filters []*Filter, r *http.Request) []*Filter {
+		return append(filters, NewFilter("status", FilterOperateEqual, "valid"))
+	})
+
+	for _, path := range []string{"/test/list", "/test/page"} {
+		testResponse := httptest.NewRecorder()
+		testRequest, _ := http.NewRequest("POST", path, strings.NewReader(`{"filters":[{"field":"name","operate":"=","value":"a"}],"skipTotal":true}`))
+		router.ServeHTTP(testResponse, testRequest)
+		if testResponse.Code != http.StatusOK {
+			t.Fatal(path, testResponse.Body.String())
+		}
+		statements := database.Statements()
+		if statement := statements[len(statements)-1]; !strings.Contains(statement, "name = ?") || !strings.Contains(statement, "status = ?") {
+			t.Fatal(path, statement)
+		}
+	}
+
+	// 客户端不可使用服务端追加的过滤列
+	for _, path := range []string{"/test/first", "/test/list", "/test/page"} {
+		testResponse := httptest.NewRecorder()
+		testRequest, _ := http.NewRequest("POST", path, strings.NewReader(`{"filters":[{"field":"status","operate":"=","value":"invalid"}]}`))
+		router.ServeHTTP(testResponse, testRequest)
+		if testResponse.Code == http.StatusOK || !strings.Contains(testResponse.Body.String(), ErrFilterField.Error()) {
+			t.Fatal(path, testResponse.Body.String())
+		}
+	}
+}
+
+func TestHTTPServicePatch(t *testing.T) {
+	for _, action := range WriteActions {
+		if action == ActionPatch {
+			t.Fatal(WriteActions)
+		}
+	}
+	db, database := newTestDB(t)
+	database.SetRows([]string{"id", "name", "status"}, []driver.Value{int64(1), "a", "valid"})
+	service, router := newTestService(t, db, ActionPatch)
+	patch := func(body string) (*httptest.ResponseRecorder, []string) {
+		before := len(database.Statements())
+		testResponse := httptest.NewRecorder()
+		testRequest, _ := http.NewRequest("PATCH", "/test/1", strings.NewReader(body))
+		router.ServeHTTP(testResponse, testRequest)
+		updates := make([]string, 0)
+		for _, statement := range database.Statements()[before:] {
+			if strings.HasPrefix(statement, "UPDATE") {
+				updates = append(updates, statement)
+			}
+		}
+		return testResponse, updates
+	}
+
+	testResponse, updates := patch(`{"name":"b"}`)
+	if testResponse.Code != http.StatusOK || len(updates) != 1 {
+		t.Fatal(testResponse.Body.String(), updates)
+	}
+	if !strings.Contains(updates[0], `"name" = ?`) || strings.Contains(updates[0], `"status"`) {
+		t.Fatal(updates[0])
+	}
+
+	if testResponse, updates = patch(`{"createdAt":"2020-01-01T00:00:00Z"}`); testResponse.Code == http.StatusOK || len(updates) != 0 {
+		t.Fatal(testResponse.Body.String(), updates)
+	}
+
+	// 未设置部分更新前函数时，合并请求体后执行更新前函数
+	service.HandleBeforeUpdateFunc(func(model interface{}, r *http.Request) (interface{}, error) {
+		updateModel := model.(*testDefaultModel)
+		if updateModel.ID != 1 || updateModel.Status != "valid" {
+			t.Fatal(updateModel)
+		}
+		if updateModel.Name == "" {
+			return nil, ErrModelCanNotUpdate
+		}
+		updateModel.Name = strings.ToUpper(updateModel.Name)
+		updateModel.Count = 5
+		return updateModel, nil
+	})
+	testResponse, updates = patch(`{"name":""}`)
+	if testResponse.Code == http.StatusOK || !strings.Contains(testResponse.Body.String(), ErrModelCanNotUpdate.Error()) || len(updates) != 0 {
+		t.Fatal(testResponse.Body.String(), updates)
+	}
+	testResponse, updates = patch(`{"name":"b"}`)
+	if testResponse.Code != http.StatusOK || len(updates) != 1 {
+		t.Fatal(testResponse.Body.String(), updates)
+	}
+	if !strings.Contains(updates[0], `"name" = ?`) || !strings.Contains(updates[0], `"count" = ?`) || strings.Contains(updates[0], `"status"`) {
+		t.Fatal(updates[0])
+	}
+	statements := database.Statements()
+	for index := len(statements) - 1; index >= 0; index-- {
+		if statements[index] == updates[0] {
+			args := database.Args(index)
+			if !reflect.DeepEqual(args[0], int64(5)) || !reflect.DeepEqual(args[1], "B") {
+				t.Fatal(args)
+			}
+			break
+		}
+	}
+
+	service.HandleBeforePatchFunc(func(model interface{}, fields map[string]interface{}, r *http.Request) (map[string]interface{}, error) {
+		if model.(*testDefaultModel).Name != "a" || fields["name"] != "b" {
+			t.Fatal(model, fields)
+		}
+		fields["status"] = "invalid"
+		return fields, nil
+	})
+	testResponse, updates = patch(`{"name":"b"}`)
+	if testResponse.Code != http.StatusOK || len(updates) != 1 || !strings.Contains(updates[0], `"status" = ?`) {
+		t.Fatal(testResponse.Body.String(), updates)
+	}
+}
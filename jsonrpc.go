@@ -0,0 +1,191 @@
+package gglmm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/rpc"
+	"strings"
+)
+
+// JSON-RPC 2.0 错误码
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+	JSONRPCServerError    = -32000 // RPCHandler方法返回的错误
+)
+
+// JSONRPCVersion --
+const JSONRPCVersion = "2.0"
+
+var errJSONRPCParams = errors.New("参数错误")
+
+// JSONRPCRequest JSON-RPC 2.0 请求，Method为 名称.方法
+type JSONRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// JSONRPCError JSON-RPC 2.0 错误
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSONRPCResponse JSON-RPC 2.0 响应
+type JSONRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+func newJSONRPCErrorResponse(id json.RawMessage, code int, message string) *JSONRPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &JSONRPCResponse{
+		JSONRPC: JSONRPCVersion,
+		Error:   &JSONRPCError{Code: code, Message: message},
+		ID:      id,
+	}
+}
+
+// jsonRPCCodec 单次调用的rpc.ServerCodec
+type jsonRPCCodec struct {
+	request     *JSONRPCRequest
+	response    *JSONRPCResponse
+	paramsError bool
+}
+
+func (codec *jsonRPCCodec) ReadRequestHeader(r *rpc.Request) error {
+	r.ServiceMethod = codec.request.Method
+	r.Seq = 0
+	return nil
+}
+
+// ReadRequestBody params为单元素数组时取该元素，否则整体解析
+func (codec *jsonRPCCodec) ReadRequestBody(body interface{}) error {
+	if body == nil {
+		return nil
+	}
+	params := bytes.TrimSpace(codec.request.Params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	if params[0] == '[' {
+		items := make([]json.RawMessage, 0)
+		if err := json.Unmarshal(params, &items); err == nil && len(items) == 1 {
+			if err := json.Unmarshal(items[0], body); err == nil {
+				return nil
+			}
+		}
+	}
+	if err := json.Unmarshal(params, body); err != nil {
+		codec.paramsError = true
+		return errJSONRPCParams
+	}
+	return nil
+}
+
+func (codec *jsonRPCCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if r.Error == "" {
+		codec.response = &JSONRPCResponse{
+			JSONRPC: JSONRPCVersion,
+			Result:  body,
+			ID:      codec.request.ID,
+		}
+		return nil
+	}
+	code := JSONRPCServerError
+	if codec.paramsError {
+		code = JSONRPCInvalidParams
+	} else if strings.HasPrefix(r.Error, "rpc: can't find") || strings.HasPrefix(r.Error, "rpc: service/method request ill-formed") {
+		code = JSONRPCMethodNotFound
+	}
+	codec.response = newJSONRPCErrorResponse(codec.request.ID, code, r.Error)
+	return nil
+}
+
+func (codec *jsonRPCCodec) Close() error {
+	return nil
+}
+
+// jsonRPCCall 执行单个调用，通知（无id）返回nil
+func (server *Server) jsonRPCCall(raw json.RawMessage, r *http.Request) *JSONRPCResponse {
+	request := JSONRPCRequest{}
+	if err := json.Unmarshal(raw, &request); err != nil || request.JSONRPC != JSONRPCVersion || request.Method == "" {
+		return newJSONRPCErrorResponse(request.ID, JSONRPCInvalidRequest, "Invalid Request")
+	}
+	codec := &jsonRPCCodec{request: &request}
+	server.rpcDispatcher.ServeRequest(codec, r)
+	if len(request.ID) == 0 {
+		return nil
+	}
+	if codec.response == nil {
+		return newJSONRPCErrorResponse(request.ID, JSONRPCInternalError, "Internal error")
+	}
+	return codec.response
+}
+
+// JSONRPC 以JSON-RPC 2.0调用已注册的RPCHandler，支持批量调用
+func (server *Server) JSONRPC(w http.ResponseWriter, r *http.Request) {
+	server.registerRPC()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeJSONRPC(w, newJSONRPCErrorResponse(nil, JSONRPCParseError, "Parse error"))
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if !json.Valid(body) {
+		writeJSONRPC(w, newJSONRPCErrorResponse(nil, JSONRPCParseError, "Parse error"))
+		return
+	}
+	if len(body) == 0 || body[0] != '[' {
+		response := server.jsonRPCCall(body, r)
+		if response == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSONRPC(w, response)
+		return
+	}
+	raws := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(body, &raws); err != nil || len(raws) == 0 {
+		writeJSONRPC(w, newJSONRPCErrorResponse(nil, JSONRPCInvalidRequest, "Invalid Request"))
+		return
+	}
+	responses := make([]*JSONRPCResponse, 0, len(raws))
+	for _, raw := range raws {
+		if response := server.jsonRPCCall(raw, r); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSONRPC(w, responses)
+}
+
+func writeJSONRPC(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// HandleJSONRPC 在path注册JSON-RPC 2.0端点（POST），通过返回的HTTPActionConfig设置中间件
+func (server *Server) HandleJSONRPC(path string) *HTTPActionConfig {
+	return server.HandleHTTPAction(path, server.JSONRPC, "POST")
+}
+
+// HandleJSONRPC 在默认实例注册JSON-RPC 2.0端点
+func HandleJSONRPC(path string) *HTTPActionConfig {
+	return defaultServer.HandleJSONRPC(path)
+}
@@ -0,0 +1,69 @@
+package gglmm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONRPC(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	server.RegisterRPC(&TestRPCService{})
+	server.HandleJSONRPC("/jsonrpc")
+	handler := server.Handler()
+
+	call := func(body string) *httptest.ResponseRecorder {
+		testResponse := httptest.NewRecorder()
+		testRequest, _ := http.NewRequest("POST", "/jsonrpc", strings.NewReader(body))
+		handler.ServeHTTP(testResponse, testRequest)
+		return testResponse
+	}
+
+	response := JSONRPCResponse{}
+	testResponse := call(`{"jsonrpc":"2.0","method":"TestRPCService.Double","params":[21],"id":1}`)
+	if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != nil || response.Result.(float64) != 42 || string(response.ID) != "1" {
+		t.Fatal(testResponse.Body.String())
+	}
+
+	responses := make([]JSONRPCResponse, 0)
+	testResponse = call(`[
+		{"jsonrpc":"2.0","method":"TestRPCService.Double","params":2,"id":"a"},
+		{"jsonrpc":"2.0","method":"TestRPCService.Double","params":3},
+		{"jsonrpc":"2.0","method":"TestRPCService.Triple","params":[1],"id":"b"},
+		{"jsonrpc":"2.0","method":"TestRPCService.Double","params":["x"],"id":"c"},
+		{"method":"TestRPCService.Double","id":"d"}
+	]`)
+	if err := json.Unmarshal(testResponse.Body.Bytes(), &responses); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 4 {
+		t.Fatal(testResponse.Body.String())
+	}
+	if responses[0].Result.(float64) != 4 {
+		t.Fatal(responses[0])
+	}
+	codes := []int{JSONRPCMethodNotFound, JSONRPCInvalidParams, JSONRPCInvalidRequest}
+	for i, code := range codes {
+		if responses[i+1].Error == nil || responses[i+1].Error.Code != code {
+			t.Fatal(testResponse.Body.String())
+		}
+	}
+
+	testResponse = call(`{"jsonrpc":"2.0","method"`)
+	if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil || response.Error.Code != JSONRPCParseError {
+		t.Fatal(testResponse.Body.String())
+	}
+
+	testResponse = call(`{"jsonrpc":"2.0","method":"TestRPCService.Double","params":[1]}`)
+	if testResponse.Code != http.StatusNoContent {
+		t.Fatal(testResponse.Code)
+	}
+}
@@ -0,0 +1,344 @@
+package gglmm
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ModelFields 模型字段声明：可过滤、可排序、可模糊查询的列
+type ModelFields struct {
+	filters map[string]bool
+	orders  map[string]bool
+	likes   map[string]bool
+	writes  map[string]bool
+	keys    map[string]string
+	columns []*ModelField
+}
+
+// ModelField 字段元数据，用于生成表单
+type ModelField struct {
+	Key        string      `json:"key"`
+	Column     string      `json:"column"`
+	Type       string      `json:"type"`
+	Nullable   bool        `json:"nullable"`
+	Default    interface{} `json:"default"`
+	Filterable bool        `json:"filterable"`
+	Orderable  bool        `json:"orderable"`
+	Likeable   bool        `json:"likeable"`
+	Writable   bool        `json:"writable"`
+}
+
+// ModelField Type
+const (
+	ModelFieldString = "string"
+	ModelFieldInt    = "int"
+	ModelFieldUint   = "uint"
+	ModelFieldFloat  = "float"
+	ModelFieldBool   = "bool"
+	ModelFieldTime   = "time"
+	ModelFieldOther  = "other"
+)
+
+// NewModelFields 根据模型的gorm标签解析字段
+// 忽略gorm:"-"、json:"-"以及关联字段
+// 所有列可过滤、可排序，字符串列可模糊查询，除主键、时间戳外的列可写
+func NewModelFields(model interface{}) *ModelFields {
+	fields := &ModelFields{
+		filters: make(map[string]bool),
+		orders:  make(map[string]bool),
+		likes:   make(map[string]bool),
+		writes:  make(map[string]bool),
+		keys:    make(map[string]string),
+		columns: make([]*ModelField, 0),
+	}
+	for _, structField := range modelStructFields(model) {
+		fieldType := structField.Struct.Type
+		column := &ModelField{
+			Key:      jsonKey(structField),
+			Column:   structField.DBName,
+			Type:     modelFieldType(fieldType),
+			Nullable: fieldType.Kind() == reflect.Ptr,
+		}
+		if value, ok := defaultValue(structField); ok {
+			column.Default = value.Interface()
+		}
+		fields.columns = append(fields.columns, column)
+		fields.filters[structField.DBName] = true
+		fields.orders[structField.DBName] = true
+		if structField.Struct.Type.Kind() == reflect.String {
+			fields.likes[structField.DBName] = true
+		}
+		if !structField.IsPrimaryKey && !readonlyColumns[structField.DBName] {
+			fields.writes[structField.DBName] = true
+		}
+		fields.keys[structField.DBName] = structField.DBName
+		fields.keys[jsonKey(structField)] = structField.DBName
+	}
+	return fields
+}
+
+var readonlyColumns = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"deleted_at": true,
+}
+
+func jsonKey(structField *gorm.StructField) string {
+	name := strings.Split(structField.Struct.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return structField.Name
+	}
+	return name
+}
+
+func modelFieldType(fieldType reflect.Type) string {
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType == reflect.TypeOf(time.Time{}) {
+		return ModelFieldTime
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		return ModelFieldString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ModelFieldInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ModelFieldUint
+	case reflect.Float32, reflect.Float64:
+		return ModelFieldFloat
+	case reflect.Bool:
+		return ModelFieldBool
+	default:
+		return ModelFieldOther
+	}
+}
+
+// defaultValue 解析gorm标签default:的值，只支持基本类型
+func defaultValue(structField *gorm.StructField) (reflect.Value, bool) {
+	tagValue, ok := structField.TagSettingsGet("DEFAULT")
+	if !ok {
+		return reflect.Value{}, false
+	}
+	tagValue = strings.Trim(tagValue, "'\"")
+	fieldType := structField.Struct.Type
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	value := reflect.New(fieldType).Elem()
+	switch fieldType.Kind() {
+	case reflect.String:
+		value.SetString(tagValue)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intValue, err := strconv.ParseInt(tagValue, 10, 64)
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		value.SetInt(intValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintValue, err := strconv.ParseUint(tagValue, 10, 64)
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		value.SetUint(uintValue)
+	case reflect.Float32, reflect.Float64:
+		floatValue, err := strconv.ParseFloat(tagValue, 64)
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		value.SetFloat(floatValue)
+	case reflect.Bool:
+		boolValue, err := strconv.ParseBool(tagValue)
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		value.SetBool(boolValue)
+	default:
+		return reflect.Value{}, false
+	}
+	return value, true
+}
+
+// SetDefaultValues 根据gorm标签default:设置模型默认值
+func SetDefaultValues(model interface{}) {
+	scope := &gorm.Scope{Value: model}
+	for _, structField := range modelStructFields(model) {
+		value, ok := defaultValue(structField)
+		if !ok {
+			continue
+		}
+		field, ok := scope.FieldByName(structField.DBName)
+		if !ok || !field.Field.CanSet() {
+			continue
+		}
+		if field.Field.Kind() == reflect.Ptr {
+			pointer := reflect.New(value.Type())
+			pointer.Elem().Set(value)
+			field.Field.Set(pointer)
+		} else {
+			field.Field.Set(value)
+		}
+	}
+}
+
+func modelStructFields(model interface{}) []*gorm.StructField {
+	scope := &gorm.Scope{Value: model}
+	structFields := make([]*gorm.StructField, 0)
+	for _, structField := range scope.GetModelStruct().StructFields {
+		if structField.IsIgnored || !structField.IsNormal {
+			continue
+		}
+		if structField.Struct.Tag.Get("json") == "-" {
+			continue
+		}
+		structFields = append(structFields, structField)
+	}
+	return structFields
+}
+
+func columnSet(columns []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, column := range columns {
+		set[column] = true
+	}
+	return set
+}
+
+// Filters 设置可过滤列
+func (fields *ModelFields) Filters(columns ...string) *ModelFields {
+	fields.filters = columnSet(columns)
+	return fields
+}
+
+// Orders 设置可排序列
+func (fields *ModelFields) Orders(columns ...string) *ModelFields {
+	fields.orders = columnSet(columns)
+	return fields
+}
+
+// Likes 设置可模糊查询列
+func (fields *ModelFields) Likes(columns ...string) *ModelFields {
+	fields.likes = columnSet(columns)
+	return fields
+}
+
+// Writes 设置可写列
+func (fields *ModelFields) Writes(columns ...string) *ModelFields {
+	fields.writes = columnSet(columns)
+	return fields
+}
+
+// Fields 字段元数据
+func (fields *ModelFields) Fields() []*ModelField {
+	result := make([]*ModelField, 0, len(fields.columns))
+	for _, column := range fields.columns {
+		field := *column
+		field.Filterable = fields.filters[field.Column]
+		field.Orderable = fields.orders[field.Column]
+		field.Likeable = fields.likes[field.Column]
+		field.Writable = fields.writes[field.Column]
+		result = append(result, &field)
+	}
+	return result
+}
+
+// CheckWrite 检查可写字段，key为json名称或列名，返回列名
+func (fields *ModelFields) CheckWrite(key string) (string, error) {
+	column, ok := fields.keys[key]
+	if !ok || !fields.writes[column] {
+		return "", ErrWriteField
+	}
+	return column, nil
+}
+
+// CheckFilter 检查过滤字段
+func (fields *ModelFields) CheckFilter(filter *Filter) error {
+	if filter == nil {
+		return ErrFilter
+	}
+	if filter.Field == FilterFieldDeleted {
+		return nil
+	}
+	if filter.IsLike() {
+		for _, field := range strings.Split(filter.Field, FilterSeparator) {
+			if !fields.likes[field] {
+				return ErrFilterField
+			}
+		}
+		return nil
+	}
+	if !fields.filters[filter.Field] {
+		return ErrFilterField
+	}
+	return nil
+}
+
+// CheckFilterGroup 检查过滤条件组
+func (fields *ModelFields) CheckFilterGroup(group *FilterGroup) error {
+	if group == nil {
+		return ErrFilter
+	}
+	for _, filter := range group.Filters {
+		if err := fields.CheckFilter(filter); err != nil {
+			return err
+		}
+	}
+	for _, subGroup := range group.Groups {
+		if err := fields.CheckFilterGroup(subGroup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckOrder 检查排序，返回规范化后的排序
+// 格式：column [asc|desc][, column [asc|desc]]
+func (fields *ModelFields) CheckOrder(order string) (string, error) {
+	if strings.TrimSpace(order) == "" {
+		return "", nil
+	}
+	orders := make([]string, 0)
+	for _, item := range strings.Split(order, ",") {
+		parts := strings.Fields(item)
+		if len(parts) == 0 || len(parts) > 2 {
+			return "", ErrFilterOrder
+		}
+		if !fields.orders[parts[0]] {
+			return "", ErrFilterOrder
+		}
+		if len(parts) == 2 {
+			direction := strings.ToLower(parts[1])
+			if direction != "asc" && direction != "desc" {
+				return "", ErrFilterOrder
+			}
+			orders = append(orders, parts[0]+" "+direction)
+		} else {
+			orders = append(orders, parts[0])
+		}
+	}
+	return strings.Join(orders, ", "), nil
+}
+
+// CheckFilterRequest 检查过滤请求
+func (fields *ModelFields) CheckFilterRequest(filterRequest *FilterRequest) error {
+	for _, filter := range filterRequest.Filters {
+		if err := fields.CheckFilter(filter); err != nil {
+			return err
+		}
+	}
+	for _, group := range filterRequest.Groups {
+		if err := fields.CheckFilterGroup(group); err != nil {
+			return err
+		}
+	}
+	order, err := fields.CheckOrder(filterRequest.Order)
+	if err != nil {
+		return err
+	}
+	filterRequest.Order = order
+	return nil
+}
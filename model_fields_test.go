@@ -0,0 +1,113 @@
+package gglmm
+
+import "testing"
+
+type testFieldsModel struct {
+	Model
+	Name     string            `json:"name"`
+	Age      int               `json:"age"`
+	Nickname string            `json:"nickname" gorm:"column:nick"`
+	Password string            `json:"-"`
+	Ignored  string            `json:"ignored" gorm:"-"`
+	Children []testFieldsChild `json:"children"`
+	Parent   *testFieldsChild  `json:"parent"`
+}
+
+type testFieldsChild struct {
+	Model
+	TestFieldsModelID uint64 `json:"testFieldsModelId"`
+}
+
+func TestModelFields(t *testing.T) {
+	fields := NewModelFields(testFieldsModel{})
+	for _, field := range []string{"id", "created_at", "deleted_at", "name", "age", "nick"} {
+		if err := fields.CheckFilter(NewFilter(field, FilterOperateEqual, 1)); err != nil {
+			t.Fatal(field, err)
+		}
+	}
+	for _, field := range []string{"password", "ignored", "children", "parent", "name = 1 or 1", "nickname"} {
+		if err := fields.CheckFilter(NewFilter(field, FilterOperateEqual, 1)); err != ErrFilterField {
+			t.Fatal(field, err)
+		}
+	}
+	if err := fields.CheckFilter(NewFilter("name|nick", FilterOperateLike, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fields.CheckFilter(NewFilter("name|age", FilterOperateLike, "a")); err != ErrFilterField {
+		t.Fatal(err)
+	}
+	if err := fields.CheckFilter(NewFilter(FilterFieldDeleted, FilterOperateEqual, FilterValueAll.Value)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModelFieldsOrder(t *testing.T) {
+	fields := NewModelFields(&testFieldsModel{})
+	order, err := fields.CheckOrder(" id DESC,name ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order != "id desc, name" {
+		t.Fatal(order)
+	}
+	for _, order := range []string{"password", "id desc desc", "id; drop table x", "(select 1)", "id,"} {
+		if _, err := fields.CheckOrder(order); err != ErrFilterOrder {
+			t.Fatal(order, err)
+		}
+	}
+	fields.Orders("id")
+	if _, err := fields.CheckOrder("name"); err != ErrFilterOrder {
+		t.Fatal(err)
+	}
+}
+
+func TestModelFieldsWrite(t *testing.T) {
+	fields := NewModelFields(testFieldsModel{})
+	for key, column := range map[string]string{"name": "name", "age": "age", "nickname": "nick", "nick": "nick"} {
+		result, err := fields.CheckWrite(key)
+		if err != nil {
+			t.Fatal(key, err)
+		}
+		if result != column {
+			t.Fatal(key, result)
+		}
+	}
+	for _, key := range []string{"id", "createdAt", "updated_at", "deletedAt", "password", "Password", "children", "unknown"} {
+		if _, err := fields.CheckWrite(key); err != ErrWriteField {
+			t.Fatal(key, err)
+		}
+	}
+}
+
+type testDefaultModel struct {
+	Model
+	Status string   `json:"status" gorm:"default:'valid'"`
+	Count  int      `json:"count" gorm:"default:3"`
+	Rate   *float64 `json:"rate" gorm:"default:0.5"`
+	Name   string   `json:"name"`
+}
+
+func TestModelFieldsDefault(t *testing.T) {
+	model := testDefaultModel{}
+	SetDefaultValues(&model)
+	if model.Status != "valid" || model.Count != 3 || model.Rate == nil || *model.Rate != 0.5 || model.Name != "" {
+		t.Fatal(model)
+	}
+	fields := NewModelFields(&model).Fields()
+	for _, field := range fields {
+		switch field.Key {
+		case "status":
+			if field.Default != "valid" || field.Type != ModelFieldString || !field.Likeable || !field.Writable {
+				t.Fatal(field)
+			}
+		case "rate":
+			if field.Default != 0.5 || field.Type != ModelFieldFloat || !field.Nullable {
+				t.Fatal(field)
+			}
+		case "createdAt":
+			if field.Type != ModelFieldTime || field.Writable || !field.Orderable {
+				t.Fatal(field)
+			}
+		}
+	}
+}
@@ -45,10 +45,10 @@ func handleHTTPFunc(subrouter *mux.Router, path string, handlerFunc http.Handler
 	subrouter.HandleFunc(path, handlerFunc).Methods(mathods...)
 }
 
-func logHTTP(methods []string, path string, middlewares []string) {
+func (server *Server) logHTTP(methods []string, path string, middlewares []string) {
 	if len(middlewares) > 0 {
-		log.Printf("[http] [%-12s] %-60s %-80s\n", strings.Join(methods, ", "), basePath+path, strings.Join(middlewares, ", "))
+		log.Printf("[http] [%-12s] %-60s %-80s\n", strings.Join(methods, ", "), server.basePath+path, strings.Join(middlewares, ", "))
 	} else {
-		log.Printf("[http] [%-12s] %-60s\n", strings.Join(methods, ", "), basePath+path)
+		log.Printf("[http] [%-12s] %-60s\n", strings.Join(methods, ", "), server.basePath+path)
 	}
 }
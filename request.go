@@ -16,11 +16,24 @@ const (
 	FilterOperateLessThan     = "<"
 	FilterOperateLessEqual    = "<="
 	FilterOperateLike         = "like"
+	FilterOperateNotLike      = "not like"
+	FilterOperateStartsWith   = "starts with"
+	FilterOperateEndsWith     = "ends with"
 	FilterOperateIn           = "in"
+	FilterOperateNotIn        = "not in"
 	FilterOperateBetween      = "between"
+	FilterOperateIsNull       = "is null"
+	FilterOperateIsNotNull    = "is not null"
 	FilterSeparator           = "|"
 )
 
+// FilterLogic 过滤组合方式
+const (
+	FilterLogicAnd = "and"
+	FilterLogicOr  = "or"
+	FilterLogicNot = "not"
+)
+
 // Filter
 var (
 	FilterFieldDeleted = "deleted"
@@ -58,12 +71,58 @@ func (filter Filter) Check() bool {
 	if filter.Operate == "" {
 		return false
 	}
+	if filter.Operate == FilterOperateIsNull || filter.Operate == FilterOperateIsNotNull {
+		return true
+	}
 	if filter.Value == nil {
 		return false
 	}
 	return true
 }
 
+// FilterGroup 过滤条件组，Filters与Groups按Logic组合
+// and: (a and b)；or: (a or b)；not: not (a and b)
+type FilterGroup struct {
+	Logic   string         `json:"logic"`
+	Filters []*Filter      `json:"filters"`
+	Groups  []*FilterGroup `json:"groups"`
+}
+
+// NewFilterGroup --
+func NewFilterGroup(logic string) *FilterGroup {
+	return &FilterGroup{
+		Logic: logic,
+	}
+}
+
+// AddFilter 添加过滤条件
+func (group *FilterGroup) AddFilter(field string, operate string, value interface{}) *FilterGroup {
+	if group.Filters == nil {
+		group.Filters = make([]*Filter, 0)
+	}
+	group.Filters = append(group.Filters, NewFilter(field, operate, value))
+	return group
+}
+
+// AddGroup 添加子条件组
+func (group *FilterGroup) AddGroup(subGroup *FilterGroup) *FilterGroup {
+	if group.Groups == nil {
+		group.Groups = make([]*FilterGroup, 0)
+	}
+	group.Groups = append(group.Groups, subGroup)
+	return group
+}
+
+// IsLike 是否模糊查询
+func (filter Filter) IsLike() bool {
+	switch filter.Operate {
+	case FilterOperateLike, FilterOperateNotLike, FilterOperateStartsWith, FilterOperateEndsWith:
+		return true
+	default:
+		return false
+	}
+}
+
 // IDRequest --
 type IDRequest struct {
 	ID       uint64   `json:"id"`
@@ -71,10 +130,12 @@ type IDRequest struct {
 }
 
 // FilterRequest 分页请求
+// Filters与Groups之间均为and关系
 type FilterRequest struct {
-	Filters  []*Filter `json:"filters"`
-	Preloads []string  `json:"preloads"`
-	Order    string    `json:"order"`
+	Filters  []*Filter      `json:"filters"`
+	Groups   []*FilterGroup `json:"groups"`
+	Preloads []string       `json:"preloads"`
+	Order    string         `json:"order"`
 }
 
 // AddFilter 添加过滤条件
@@ -85,15 +146,41 @@ func (request *FilterRequest) AddFilter(field string, operate string, value inte
 	request.Filters = append(request.Filters, NewFilter(field, operate, value))
 }
 
+// AddGroup 添加过滤条件组
+func (request *FilterRequest) AddGroup(group *FilterGroup) {
+	if request.Groups == nil {
+		request.Groups = make([]*FilterGroup, 0)
+	}
+	request.Groups = append(request.Groups, group)
+}
+
 // Pagination 分页
 type Pagination struct {
-	PageSize  int `json:"pageSize"`
-	PageIndex int `json:"pageIndex"`
-	Total     int `json:"total"`
+	PageSize  int  `json:"pageSize"`
+	PageIndex int  `json:"pageIndex"`
+	Total     int  `json:"total"`
+	HasMore   bool `json:"hasMore"`
+}
+
+// Cursor 游标分页请求，After、Before均为空时拉取第一页
+type Cursor struct {
+	After  string `json:"after"`
+	Before string `json:"before"`
+}
+
+// CursorPagination 游标分页
+type CursorPagination struct {
+	PageSize   int    `json:"pageSize"`
+	NextCursor string `json:"nextCursor"`
+	PrevCursor string `json:"prevCursor"`
 }
 
 // PageRequest 分页请求
+// Cursor不为空时使用游标分页，否则使用Pagination分页
+// SkipTotal为true时不统计总数，只返回HasMore
 type PageRequest struct {
 	FilterRequest
 	Pagination
+	Cursor    *Cursor `json:"cursor"`
+	SkipTotal bool    `json:"skipTotal"`
 }
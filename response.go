@@ -94,4 +94,5 @@ func (response Response) JSON(w http.ResponseWriter) {
 type PageResponse struct {
 	List interface{}
 	Pagination
+	Cursor *CursorPagination
 }
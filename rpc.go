@@ -39,50 +39,99 @@ type RPCHandler interface {
 
 // RPCHandlerConfig --
 type RPCHandlerConfig struct {
-	name       string
-	rpcHandler RPCHandler
+	name         string
+	rpcHandler   RPCHandler
+	interceptors []*RPCInterceptor
 }
 
-var rpcHandlerConfigs []*RPCHandlerConfig = nil
+// Interceptor 设置RPCHandler的拦截器，在全局拦截器之后执行
+func (config *RPCHandlerConfig) Interceptor(interceptors ...*RPCInterceptor) *RPCHandlerConfig {
+	config.interceptors = interceptors
+	return config
+}
 
-// RegisterRPC 注册RPCHandler
+// RPCNamer 实现时RegisterRPC使用RPCName()作为名称
+type RPCNamer interface {
+	RPCName() string
+}
+
+// RegisterRPC 注册RPCHandler，名称为类型名或RPCName()
 // rpcHandler 处理者
-func RegisterRPC(rpcHandler RPCHandler) *RPCHandlerConfig {
+func (server *Server) RegisterRPC(rpcHandler RPCHandler) *RPCHandlerConfig {
+	if namer, ok := rpcHandler.(RPCNamer); ok {
+		return server.RegisterRPCName(namer.RPCName(), rpcHandler)
+	}
 	handlerType := reflect.TypeOf(rpcHandler)
 	if handlerType.Kind() == reflect.Ptr {
 		handlerType = handlerType.Elem()
 	}
 	name := handlerType.Name()
-	return RegisterRPCName(name, rpcHandler)
+	return server.RegisterRPCName(name, rpcHandler)
 }
 
 // RegisterRPCName 注册RPCHandler
 // name 名称
 // rpcHandler 处理者
-func RegisterRPCName(name string, rpcHandler RPCHandler) *RPCHandlerConfig {
-	if rpcHandlerConfigs == nil {
-		rpcHandlerConfigs = make([]*RPCHandlerConfig, 0)
-	}
+func (server *Server) RegisterRPCName(name string, rpcHandler RPCHandler) *RPCHandlerConfig {
 	config := &RPCHandlerConfig{
 		name:       name,
 		rpcHandler: rpcHandler,
 	}
-	rpcHandlerConfigs = append(rpcHandlerConfigs, config)
+	server.rpcHandlerConfigs = append(server.rpcHandlerConfigs, config)
 	return config
 }
 
-func registerRPC() {
-	if rpcHandlerConfigs == nil || len(rpcHandlerConfigs) == 0 {
+// RegisterRPC 注册RPCHandler
+// rpcHandler 处理者
+func RegisterRPC(rpcHandler RPCHandler) *RPCHandlerConfig {
+	return defaultServer.RegisterRPC(rpcHandler)
+}
+
+// RegisterRPCName 注册RPCHandler
+// name 名称
+// rpcHandler 处理者
+func RegisterRPCName(name string, rpcHandler RPCHandler) *RPCHandlerConfig {
+	return defaultServer.RegisterRPCName(name, rpcHandler)
+}
+
+// registerRPC 注册到rpcDispatcher，Handler、ServeRPC共用，只执行一次
+func (server *Server) registerRPC() {
+	server.rpcOnce.Do(server.doRegisterRPC)
+}
+
+func (server *Server) doRegisterRPC() {
+	if len(server.rpcHandlerConfigs) == 0 {
 		return
 	}
-	for _, config := range rpcHandlerConfigs {
-		rpcActionsResponse := RPCActionsResponse{}
-		config.rpcHandler.Actions("all", &rpcActionsResponse)
-		rpcInfos := []string{}
-		for _, action := range rpcActionsResponse.Actions {
-			rpcInfos = append(rpcInfos, action.String())
+	for _, config := range server.rpcHandlerConfigs {
+		server.registerRPCService(config)
+		if server.rpcDefault {
+			// 默认实例同时注册到rpc.DefaultServer，直接使用rpc.DefaultServer时仍可调用，不经过RPC拦截器
+			if err := rpc.DefaultServer.RegisterName(config.name, config.rpcHandler); err != nil {
+				log.Println(err)
+			}
 		}
-		rpc.RegisterName(config.name, config.rpcHandler)
-		log.Printf("[ rpc] %s [%s]\n", config.name, strings.Join(rpcInfos, "; "))
 	}
+	server.registerRPCService(&RPCHandlerConfig{
+		name:       RPCDiscoveryName,
+		rpcHandler: &rpcDiscovery{dispatcher: server.rpcDispatcher},
+	})
+}
+
+func (server *Server) registerRPCService(config *RPCHandlerConfig) {
+	interceptors := server.rpcHandlerInterceptors(config)
+	service, err := server.rpcDispatcher.register(config.name, config.rpcHandler, interceptors)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	rpcInfos := []string{}
+	for _, method := range service.info().Methods {
+		rpcInfos = append(rpcInfos, method.Name+"("+method.Request+", "+method.Response+")")
+	}
+	interceptorNames := make([]string, 0, len(interceptors))
+	for _, interceptor := range interceptors {
+		interceptorNames = append(interceptorNames, interceptor.Name)
+	}
+	log.Printf("[ rpc] %s [%s] [%s]\n", config.name, strings.Join(rpcInfos, "; "), strings.Join(interceptorNames, ", "))
 }
@@ -0,0 +1,94 @@
+package gglmm
+
+import (
+	"net/http"
+	"sort"
+)
+
+// RPCDiscoveryName 发现服务的注册名称，方法为RPCDiscovery.Services
+const RPCDiscoveryName = "RPCDiscovery"
+
+// RPCMethodInfo RPC方法，请求、响应类型由反射得到
+type RPCMethodInfo struct {
+	Name     string `json:"name"`
+	Request  string `json:"request"`
+	Response string `json:"response"`
+}
+
+// RPCServiceInfo RPC服务
+type RPCServiceInfo struct {
+	Name    string           `json:"name"`
+	Methods []*RPCMethodInfo `json:"methods"`
+}
+
+// RPCServicesResponse --
+type RPCServicesResponse struct {
+	Services []*RPCServiceInfo `json:"services"`
+}
+
+func (service *rpcService) info() *RPCServiceInfo {
+	serviceInfo := &RPCServiceInfo{
+		Name:    service.name,
+		Methods: make([]*RPCMethodInfo, 0, len(service.methods)),
+	}
+	for name, method := range service.methods {
+		serviceInfo.Methods = append(serviceInfo.Methods, &RPCMethodInfo{
+			Name:     name,
+			Request:  method.argType.String(),
+			Response: method.replyType.String(),
+		})
+	}
+	sort.Slice(serviceInfo.Methods, func(i, j int) bool {
+		return serviceInfo.Methods[i].Name < serviceInfo.Methods[j].Name
+	})
+	return serviceInfo
+}
+
+// serviceInfos 已注册的服务，按名称排序
+func (dispatcher *rpcDispatcher) serviceInfos() []*RPCServiceInfo {
+	dispatcher.mutex.RLock()
+	defer dispatcher.mutex.RUnlock()
+	services := make([]*RPCServiceInfo, 0, len(dispatcher.services))
+	for _, service := range dispatcher.services {
+		services = append(services, service.info())
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+// rpcDiscovery 发现服务
+type rpcDiscovery struct {
+	dispatcher *rpcDispatcher
+}
+
+// Services 列出已注册的服务、方法以及请求、响应类型
+func (discovery *rpcDiscovery) Services(cmd string, response *RPCServicesResponse) error {
+	response.Services = discovery.dispatcher.serviceInfos()
+	return nil
+}
+
+// RPCDiscovery HTTP发现接口
+func (server *Server) RPCDiscovery(w http.ResponseWriter, r *http.Request) {
+	server.registerRPC()
+	OkResponse().
+		AddData("services", server.rpcDispatcher.serviceInfos()).
+		JSON(w)
+}
+
+// HandleRPCDiscovery 在path注册HTTP发现接口（GET），通过返回的HTTPActionConfig设置中间件
+func (server *Server) HandleRPCDiscovery(path string) *HTTPActionConfig {
+	return server.HandleHTTPAction(path, server.RPCDiscovery, "GET")
+}
+
+// HandleRPCDiscovery 在默认实例注册HTTP发现接口
+func HandleRPCDiscovery(path string) *HTTPActionConfig {
+	return defaultServer.HandleRPCDiscovery(path)
+}
+
+// Actions --
+func (discovery *rpcDiscovery) Actions(cmd string, actions *RPCActionsResponse) error {
+	actions.Actions = append(actions.Actions, NewRPCAction("Services", "string", "*gglmm.RPCServicesResponse"))
+	return nil
+}
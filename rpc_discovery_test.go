@@ -0,0 +1,42 @@
+package gglmm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRPCDiscovery(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	server.RegisterRPC(&TestRPCService{})
+	server.HandleRPCDiscovery("/rpc/services")
+	handler := server.Handler()
+
+	testResponse := httptest.NewRecorder()
+	testRequest, _ := http.NewRequest("GET", "/rpc/services", nil)
+	handler.ServeHTTP(testResponse, testRequest)
+	response := struct {
+		Data RPCServicesResponse `json:"data"`
+	}{}
+	if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	services := response.Data.Services
+	if len(services) != 2 || services[0].Name != RPCDiscoveryName || services[1].Name != "TestRPCService" {
+		t.Fatal(testResponse.Body.String())
+	}
+	methods := services[1].Methods
+	if len(methods) != 3 || methods[1].Name != "Double" || methods[1].Request != "int" || methods[1].Response != "*int" {
+		t.Fatal(testResponse.Body.String())
+	}
+
+	servicesResponse := RPCServicesResponse{}
+	discovery := &rpcDiscovery{dispatcher: server.rpcDispatcher}
+	if err := discovery.Services("all", &servicesResponse); err != nil {
+		t.Fatal(err)
+	}
+	if len(servicesResponse.Services) != 2 {
+		t.Fatal(servicesResponse.Services)
+	}
+}
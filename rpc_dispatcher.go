@@ -0,0 +1,357 @@
+package gglmm
+
+import (
+	"errors"
+	"go/token"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var typeOfError = reflect.TypeOf((*error)(nil)).Elem()
+
+// rpcMethod RPC方法：func (t *T) Method(args T1, reply *T2) error
+type rpcMethod struct {
+	method    reflect.Method
+	argType   reflect.Type
+	replyType reflect.Type
+}
+
+// rpcService 注册的RPCHandler
+type rpcService struct {
+	name         string
+	rcvr         reflect.Value
+	typ          reflect.Type
+	methods      map[string]*rpcMethod
+	interceptors []*RPCInterceptor
+}
+
+// rpcCallBinder 需要调用信息的RPCHandler，每次调用返回绑定了RPCCall的同类型接收者
+type rpcCallBinder interface {
+	bindRPCCall(call *RPCCall) interface{}
+}
+
+// rpcDispatcher 与net/rpc协议一致的RPC服务，调用经过拦截器链
+// 未注册的服务转发到fallback（如rpc.DefaultServer），不经过拦截器链
+type rpcDispatcher struct {
+	mutex    sync.RWMutex
+	services map[string]*rpcService
+	fallback *rpc.Server
+}
+
+func newRPCDispatcher(fallback *rpc.Server) *rpcDispatcher {
+	return &rpcDispatcher{
+		services: make(map[string]*rpcService),
+		fallback: fallback,
+	}
+}
+
+func isExportedOrBuiltinType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return token.IsExported(t.Name()) || t.PkgPath() == ""
+}
+
+// rpcMethods 与net/rpc规则一致的可调用方法
+func rpcMethods(typ reflect.Type) map[string]*rpcMethod {
+	methods := make(map[string]*rpcMethod)
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		methodType := method.Type
+		if method.PkgPath != "" || methodType.NumIn() != 3 || methodType.NumOut() != 1 {
+			continue
+		}
+		argType := methodType.In(1)
+		replyType := methodType.In(2)
+		if !isExportedOrBuiltinType(argType) || replyType.Kind() != reflect.Ptr || !isExportedOrBuiltinType(replyType) {
+			continue
+		}
+		if methodType.Out(0) != typeOfError {
+			continue
+		}
+		methods[method.Name] = &rpcMethod{
+			method:    method,
+			argType:   argType,
+			replyType: replyType,
+		}
+	}
+	return methods
+}
+
+func (dispatcher *rpcDispatcher) register(name string, rcvr interface{}, interceptors []*RPCInterceptor) (*rpcService, error) {
+	service := &rpcService{
+		name:         name,
+		rcvr:         reflect.ValueOf(rcvr),
+		typ:          reflect.TypeOf(rcvr),
+		interceptors: interceptors,
+	}
+	if name == "" {
+		return nil, errors.New("rpc.Register: no service name for type " + service.typ.String())
+	}
+	service.methods = rpcMethods(service.typ)
+	if len(service.methods) == 0 {
+		return nil, errors.New("rpc.Register: type " + name + " has no exported methods of suitable type")
+	}
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	if _, ok := dispatcher.services[name]; ok {
+		return nil, errors.New("rpc: service already defined: " + name)
+	}
+	dispatcher.services[name] = service
+	return service, nil
+}
+
+// rpcRequest 读取的请求，fallback为true时只读取了请求头
+type rpcRequest struct {
+	header   rpc.Request
+	service  *rpcService
+	method   *rpcMethod
+	argv     reflect.Value
+	replyv   reflect.Value
+	fallback bool
+}
+
+// invalidRPCRequest 出错时的响应体
+type invalidRPCRequest struct{}
+
+// readRequest 读取请求，keepReading为false时连接不可继续读取；错误时request不为nil则需响应
+func (dispatcher *rpcDispatcher) readRequest(codec rpc.ServerCodec) (request *rpcRequest, keepReading bool, err error) {
+	request = &rpcRequest{}
+	if err = codec.ReadRequestHeader(&request.header); err != nil {
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			err = errors.New("rpc: server cannot decode request: " + err.Error())
+		}
+		return nil, false, err
+	}
+	keepReading = true
+
+	serviceMethod := request.header.ServiceMethod
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		codec.ReadRequestBody(nil)
+		return request, keepReading, errors.New("rpc: service/method request ill-formed: " + serviceMethod)
+	}
+	serviceName := serviceMethod[:dot]
+	methodName := serviceMethod[dot+1:]
+	dispatcher.mutex.RLock()
+	request.service = dispatcher.services[serviceName]
+	dispatcher.mutex.RUnlock()
+	if request.service == nil {
+		if dispatcher.fallback != nil {
+			request.fallback = true
+			return request, keepReading, nil
+		}
+		codec.ReadRequestBody(nil)
+		return request, keepReading, errors.New("rpc: can't find service " + serviceMethod)
+	}
+	request.method = request.service.methods[methodName]
+	if request.method == nil {
+		codec.ReadRequestBody(nil)
+		return request, keepReading, errors.New("rpc: can't find method " + serviceMethod)
+	}
+
+	argIsValue := false
+	if request.method.argType.Kind() == reflect.Ptr {
+		request.argv = reflect.New(request.method.argType.Elem())
+	} else {
+		request.argv = reflect.New(request.method.argType)
+		argIsValue = true
+	}
+	if err = codec.ReadRequestBody(request.argv.Interface()); err != nil {
+		return request, keepReading, err
+	}
+	if argIsValue {
+		request.argv = request.argv.Elem()
+	}
+	request.replyv = reflect.New(request.method.replyType.Elem())
+	switch request.method.replyType.Elem().Kind() {
+	case reflect.Map:
+		request.replyv.Elem().Set(reflect.MakeMap(request.method.replyType.Elem()))
+	case reflect.Slice:
+		request.replyv.Elem().Set(reflect.MakeSlice(request.method.replyType.Elem(), 0, 0))
+	}
+	return request, keepReading, nil
+}
+
+func (dispatcher *rpcDispatcher) sendResponse(sending *sync.Mutex, request *rpcRequest, reply interface{}, codec rpc.ServerCodec, errmsg string) {
+	response := rpc.Response{
+		ServiceMethod: request.header.ServiceMethod,
+		Seq:           request.header.Seq,
+	}
+	if errmsg != "" {
+		response.Error = errmsg
+		reply = invalidRPCRequest{}
+	}
+	sending.Lock()
+	if err := codec.WriteResponse(&response, reply); err != nil {
+		log.Println("rpc: writing response:", err)
+	}
+	sending.Unlock()
+}
+
+// call 经过拦截器链调用方法
+func (dispatcher *rpcDispatcher) call(sending *sync.Mutex, codec rpc.ServerCodec, request *rpcRequest, r *http.Request) {
+	method := request.method
+	call := &RPCCall{
+		ServiceMethod: request.header.ServiceMethod,
+		Service:       request.service.name,
+		Method:        method.method.Name,
+		Args:          request.argv.Interface(),
+		Reply:         request.replyv.Interface(),
+		Request:       r,
+	}
+	invoker := func(call *RPCCall) error {
+		rcvr := request.service.rcvr
+		if binder, ok := rcvr.Interface().(rpcCallBinder); ok {
+			rcvr = reflect.ValueOf(binder.bindRPCCall(call))
+		}
+		returns := method.method.Func.Call([]reflect.Value{rcvr, request.argv, request.replyv})
+		if err := returns[0].Interface(); err != nil {
+			return err.(error)
+		}
+		return nil
+	}
+	errmsg := ""
+	if err := chainRPCInterceptors(request.service.interceptors, invoker)(call); err != nil {
+		errmsg = err.Error()
+	}
+	dispatcher.sendResponse(sending, request, request.replyv.Interface(), codec, errmsg)
+}
+
+// ServeCodec 处理连接上的请求直到连接关闭，每个调用在单独的goroutine中执行
+func (dispatcher *rpcDispatcher) ServeCodec(codec rpc.ServerCodec) {
+	sending := &sync.Mutex{}
+	waitGroup := &sync.WaitGroup{}
+	for {
+		request, keepReading, err := dispatcher.readRequest(codec)
+		if err != nil {
+			if err != io.EOF {
+				log.Println("rpc:", err)
+			}
+			if !keepReading {
+				break
+			}
+			if request != nil {
+				dispatcher.sendResponse(sending, request, invalidRPCRequest{}, codec, err.Error())
+			}
+			continue
+		}
+		waitGroup.Add(1)
+		if request.fallback {
+			fallbackCodec := newRPCFallbackCodec(codec, request, sending)
+			go func() {
+				defer waitGroup.Done()
+				defer fallbackCodec.bodyDone()
+				dispatcher.fallback.ServeRequest(fallbackCodec)
+			}()
+			// 请求体读取后才能读取下一个请求
+			<-fallbackCodec.chanBody
+			continue
+		}
+		go func() {
+			defer waitGroup.Done()
+			dispatcher.call(sending, codec, request, nil)
+		}()
+	}
+	waitGroup.Wait()
+	codec.Close()
+}
+
+// ServeRequest 同步处理单个请求，r为HTTP请求（JSON-RPC），不关闭codec
+func (dispatcher *rpcDispatcher) ServeRequest(codec rpc.ServerCodec, r *http.Request) error {
+	sending := &sync.Mutex{}
+	request, keepReading, err := dispatcher.readRequest(codec)
+	if err != nil {
+		if !keepReading {
+			return err
+		}
+		if request != nil {
+			dispatcher.sendResponse(sending, request, invalidRPCRequest{}, codec, err.Error())
+		}
+		return err
+	}
+	if request.fallback {
+		return dispatcher.fallback.ServeRequest(newRPCFallbackCodec(codec, request, sending))
+	}
+	dispatcher.call(sending, codec, request, r)
+	return nil
+}
+
+// rpcFallbackCodec 转发到fallback的请求：返回已读取的请求头，读取请求体后通知，与其它调用共用发送锁，不关闭连接
+type rpcFallbackCodec struct {
+	rpc.ServerCodec
+	header   rpc.Request
+	sending  *sync.Mutex
+	once     sync.Once
+	chanBody chan struct{}
+}
+
+func newRPCFallbackCodec(codec rpc.ServerCodec, request *rpcRequest, sending *sync.Mutex) *rpcFallbackCodec {
+	fallbackCodec := &rpcFallbackCodec{
+		ServerCodec: codec,
+		sending:     sending,
+		chanBody:    make(chan struct{}),
+	}
+	fallbackCodec.header.ServiceMethod = request.header.ServiceMethod
+	fallbackCodec.header.Seq = request.header.Seq
+	return fallbackCodec
+}
+
+func (codec *rpcFallbackCodec) bodyDone() {
+	codec.once.Do(func() {
+		close(codec.chanBody)
+	})
+}
+
+func (codec *rpcFallbackCodec) ReadRequestHeader(r *rpc.Request) error {
+	r.ServiceMethod = codec.header.ServiceMethod
+	r.Seq = codec.header.Seq
+	return nil
+}
+
+func (codec *rpcFallbackCodec) ReadRequestBody(body interface{}) error {
+	defer codec.bodyDone()
+	return codec.ServerCodec.ReadRequestBody(body)
+}
+
+func (codec *rpcFallbackCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	codec.sending.Lock()
+	defer codec.sending.Unlock()
+	return codec.ServerCodec.WriteResponse(r, body)
+}
+
+func (codec *rpcFallbackCodec) Close() error {
+	return nil
+}
+
+// ServeHTTP 与rpc.Server.ServeHTTP一致，可使用rpc.DialHTTP连接
+func (dispatcher *rpcDispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn := hijackRPC(w, r)
+	if conn == nil {
+		return
+	}
+	dispatcher.ServeCodec(newRPCServerCodec(conn, nil))
+}
+
+// hijackRPC 接管RPC-over-HTTP连接，失败时返回nil
+func hijackRPC(w http.ResponseWriter, r *http.Request) net.Conn {
+	if r.Method != "CONNECT" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, "405 must CONNECT\n")
+		return nil
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		log.Print("rpc hijacking ", r.RemoteAddr, ": ", err.Error())
+		return nil
+	}
+	io.WriteString(conn, "HTTP/1.0 200 Connected to Go RPC\n\n")
+	return conn
+}
@@ -0,0 +1,100 @@
+package gglmm
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RPCCall RPC调用信息
+type RPCCall struct {
+	ServiceMethod string // 名称.方法
+	Service       string
+	Method        string
+	Args          interface{}   // 参数
+	Reply         interface{}   // 结果指针，调用后有效
+	Request       *http.Request // JSON-RPC调用时的HTTP请求，其它为nil
+}
+
+// RPCInvoker 执行调用
+type RPCInvoker func(call *RPCCall) error
+
+// RPCInterceptor RPC拦截器，Func中调用next继续，返回错误则中止调用
+type RPCInterceptor struct {
+	Name string
+	Func func(call *RPCCall, next RPCInvoker) error
+}
+
+// chainRPCInterceptors 按顺序组合拦截器，第一个在最外层
+func chainRPCInterceptors(interceptors []*RPCInterceptor, invoker RPCInvoker) RPCInvoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		next := invoker
+		invoker = func(call *RPCCall) error {
+			return interceptor.Func(call, next)
+		}
+	}
+	return invoker
+}
+
+// UseRPCInterceptor 添加全局RPC拦截器，作用于之后注册到rpc的所有RPCHandler
+func (server *Server) UseRPCInterceptor(interceptors ...*RPCInterceptor) {
+	server.rpcInterceptors = append(server.rpcInterceptors, interceptors...)
+}
+
+// UseRPCInterceptor 添加默认实例的全局RPC拦截器
+func UseRPCInterceptor(interceptors ...*RPCInterceptor) {
+	defaultServer.UseRPCInterceptor(interceptors...)
+}
+
+// rpcHandlerInterceptors RPCHandler的拦截器：PanicRecovery、全局、RPCHandlerConfig、TimeLogger
+func (server *Server) rpcHandlerInterceptors(config *RPCHandlerConfig) []*RPCInterceptor {
+	interceptors := make([]*RPCInterceptor, 0)
+	if server.usePanicResponser {
+		interceptors = append(interceptors, RPCInterceptorPanicRecovery())
+	}
+	interceptors = append(interceptors, server.rpcInterceptors...)
+	interceptors = append(interceptors, config.interceptors...)
+	if server.useTimeLogger {
+		interceptors = append(interceptors, RPCInterceptorTimeLogger(server.timeLoggerThreshold))
+	}
+	return interceptors
+}
+
+// RPCInterceptorPanicRecovery 方法panic时返回错误，不影响连接上的其它调用
+func RPCInterceptorPanicRecovery() *RPCInterceptor {
+	return &RPCInterceptor{
+		Name: "PanicRecovery",
+		Func: func(call *RPCCall, next RPCInvoker) (err error) {
+			defer func() {
+				if recover := recover(); recover != nil {
+					log.Printf("rpc panic %s: %v", call.ServiceMethod, recover)
+					if errFileLine, ok := recover.(*ErrFileLine); ok {
+						err = errFileLine
+					} else {
+						err = fmt.Errorf("rpc: panic: %v", recover)
+					}
+				}
+			}()
+			return next(call)
+		},
+	}
+}
+
+// RPCInterceptorTimeLogger 调用时间超过threshold（纳秒）时打印
+func RPCInterceptorTimeLogger(threshold int64) *RPCInterceptor {
+	return &RPCInterceptor{
+		Name: fmt.Sprintf("%s[%dms]", "TimeLogger", threshold),
+		Func: func(call *RPCCall, next RPCInvoker) error {
+			start := time.Now()
+			defer func() {
+				elapsedTime := time.Now().Sub(start)
+				if elapsedTime.Nanoseconds() > threshold {
+					log.Printf("%-8dms %8s %s", elapsedTime.Nanoseconds()/1000/1000, "RPC", call.ServiceMethod)
+				}
+			}()
+			return next(call)
+		},
+	}
+}
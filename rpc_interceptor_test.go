@@ -0,0 +1,62 @@
+package gglmm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRPCInterceptor(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	calls := make([]string, 0)
+	server.UseRPCInterceptor(&RPCInterceptor{
+		Name: "Auth",
+		Func: func(call *RPCCall, next RPCInvoker) error {
+			if call.Request == nil || call.Request.Header.Get("Authorization") != "token" {
+				return errors.New("unauthorized")
+			}
+			return next(call)
+		},
+	})
+	server.RegisterRPC(&TestRPCService{}).Interceptor(&RPCInterceptor{
+		Name: "Recorder",
+		Func: func(call *RPCCall, next RPCInvoker) error {
+			err := next(call)
+			calls = append(calls, call.Method)
+			if err == nil && *call.Reply.(*int) != call.Args.(int)*2 {
+				t.Fatal(call)
+			}
+			return err
+		},
+	})
+	server.HandleJSONRPC("/jsonrpc")
+	handler := server.Handler()
+
+	call := func(method string, authorization string) *JSONRPCResponse {
+		testResponse := httptest.NewRecorder()
+		testRequest, _ := http.NewRequest("POST", "/jsonrpc", strings.NewReader(`{"jsonrpc":"2.0","method":"`+method+`","params":[1],"id":1}`))
+		testRequest.Header.Set("Authorization", authorization)
+		handler.ServeHTTP(testResponse, testRequest)
+		response := JSONRPCResponse{}
+		if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return &response
+	}
+
+	if response := call("TestRPCService.Double", ""); response.Error == nil || response.Error.Message != "unauthorized" {
+		t.Fatal(response)
+	}
+	if response := call("TestRPCService.Double", "token"); response.Error != nil || response.Result.(float64) != 2 {
+		t.Fatal(response.Error)
+	}
+	if response := call("TestRPCService.Panic", "token"); response.Error == nil || response.Error.Code != JSONRPCServerError {
+		t.Fatal(response)
+	}
+	if len(calls) != 1 || calls[0] != "Double" {
+		t.Fatal(calls)
+	}
+}
@@ -0,0 +1,250 @@
+package gglmm
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// ErrRPCListenerClosed --
+var ErrRPCListenerClosed = errors.New("RPC监听已关闭")
+
+// rpcListener 单独监听的RPC服务，listener为nil时只记录RPC-over-HTTP接管的连接
+type rpcListener struct {
+	dispatcher *rpcDispatcher
+	listener   net.Listener
+	chanConns  chan struct{} // 限制连接数，为nil时不限制
+
+	mutex  sync.Mutex
+	conns  map[net.Conn]bool
+	calls  int
+	closed bool
+}
+
+func newRPCListener(dispatcher *rpcDispatcher, listener net.Listener, maxConns int) *rpcListener {
+	rpcListener := &rpcListener{
+		dispatcher: dispatcher,
+		listener:   listener,
+		conns:      make(map[net.Conn]bool),
+	}
+	if maxConns > 0 {
+		rpcListener.chanConns = make(chan struct{}, maxConns)
+	}
+	return rpcListener
+}
+
+// serve 接受连接直到close，close后返回nil
+func (rpcListener *rpcListener) serve() error {
+	for {
+		if rpcListener.chanConns != nil {
+			rpcListener.chanConns <- struct{}{}
+		}
+		conn, err := rpcListener.listener.Accept()
+		if err != nil {
+			rpcListener.releaseConn()
+			if rpcListener.isClosed() {
+				return nil
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				log.Println("rpc accept:", err)
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if !rpcListener.addConn(conn) {
+			conn.Close()
+			rpcListener.releaseConn()
+			return nil
+		}
+		go func() {
+			defer rpcListener.releaseConn()
+			defer rpcListener.removeConn(conn)
+			rpcListener.dispatcher.ServeCodec(newRPCServerCodec(conn, rpcListener))
+		}()
+	}
+}
+
+func (rpcListener *rpcListener) releaseConn() {
+	if rpcListener.chanConns != nil {
+		<-rpcListener.chanConns
+	}
+}
+
+func (rpcListener *rpcListener) isClosed() bool {
+	rpcListener.mutex.Lock()
+	defer rpcListener.mutex.Unlock()
+	return rpcListener.closed
+}
+
+func (rpcListener *rpcListener) addConn(conn net.Conn) bool {
+	rpcListener.mutex.Lock()
+	defer rpcListener.mutex.Unlock()
+	if rpcListener.closed {
+		return false
+	}
+	rpcListener.conns[conn] = true
+	return true
+}
+
+func (rpcListener *rpcListener) removeConn(conn net.Conn) {
+	rpcListener.mutex.Lock()
+	defer rpcListener.mutex.Unlock()
+	delete(rpcListener.conns, conn)
+}
+
+func (rpcListener *rpcListener) addCall(delta int) {
+	rpcListener.mutex.Lock()
+	defer rpcListener.mutex.Unlock()
+	rpcListener.calls += delta
+}
+
+// shutdown 停止接受新连接，等待处理中的调用结束后关闭所有连接
+// ctx超时后直接关闭连接，返回ctx.Err()
+func (rpcListener *rpcListener) shutdown(ctx context.Context) error {
+	rpcListener.mutex.Lock()
+	rpcListener.closed = true
+	rpcListener.mutex.Unlock()
+	if rpcListener.listener != nil {
+		rpcListener.listener.Close()
+	}
+
+	var err error
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		rpcListener.mutex.Lock()
+		calls := rpcListener.calls
+		rpcListener.mutex.Unlock()
+		if calls == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+
+	rpcListener.mutex.Lock()
+	defer rpcListener.mutex.Unlock()
+	for conn := range rpcListener.conns {
+		conn.Close()
+	}
+	return err
+}
+
+// ServeHTTP 处理RPC-over-HTTP，记录接管的连接，shutdown时等待处理中的调用结束后关闭
+func (rpcListener *rpcListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn := hijackRPC(w, r)
+	if conn == nil {
+		return
+	}
+	if !rpcListener.addConn(conn) {
+		conn.Close()
+		return
+	}
+	defer rpcListener.removeConn(conn)
+	rpcListener.dispatcher.ServeCodec(newRPCServerCodec(conn, rpcListener))
+}
+
+// rpcServerCodec gob编码，与net/rpc一致，rpcListener不为nil时记录处理中的调用
+type rpcServerCodec struct {
+	rwc         io.ReadWriteCloser
+	decoder     *gob.Decoder
+	encoder     *gob.Encoder
+	encodeBuf   *bufio.Writer
+	rpcListener *rpcListener
+	closed      bool
+}
+
+func newRPCServerCodec(conn io.ReadWriteCloser, rpcListener *rpcListener) *rpcServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &rpcServerCodec{
+		rwc:         conn,
+		decoder:     gob.NewDecoder(conn),
+		encoder:     gob.NewEncoder(buf),
+		encodeBuf:   buf,
+		rpcListener: rpcListener,
+	}
+}
+
+func (codec *rpcServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := codec.decoder.Decode(r); err != nil {
+		return err
+	}
+	if codec.rpcListener != nil {
+		codec.rpcListener.addCall(1)
+	}
+	return nil
+}
+
+func (codec *rpcServerCodec) ReadRequestBody(body interface{}) error {
+	return codec.decoder.Decode(body)
+}
+
+func (codec *rpcServerCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	if codec.rpcListener != nil {
+		defer codec.rpcListener.addCall(-1)
+	}
+	if err = codec.encoder.Encode(r); err != nil {
+		if codec.encodeBuf.Flush() == nil {
+			log.Println("rpc: gob error encoding response:", err)
+			codec.Close()
+		}
+		return
+	}
+	if err = codec.encoder.Encode(body); err != nil {
+		if codec.encodeBuf.Flush() == nil {
+			log.Println("rpc: gob error encoding body:", err)
+			codec.Close()
+		}
+		return
+	}
+	return codec.encodeBuf.Flush()
+}
+
+func (codec *rpcServerCodec) Close() error {
+	if codec.closed {
+		return nil
+	}
+	codec.closed = true
+	return codec.rwc.Close()
+}
+
+// ServeRPC 在config.Network、config.Address（tcp或unix）上单独监听RPC，收到SIGINT、SIGTERM时优雅关闭
+// config.MaxConns大于0时限制同时连接数
+func (server *Server) ServeRPC(config ConfigRPC) {
+	listener, err := net.Listen(config.Network, config.Address)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("rpc listen on: %s %s\n", config.Network, listener.Addr())
+
+	server.registerRPC()
+	rpcListener := newRPCListener(server.rpcDispatcher, listener, config.MaxConns)
+	server.mutex.Lock()
+	server.rpcListeners = append(server.rpcListeners, rpcListener)
+	server.mutex.Unlock()
+	server.serve(rpcListener.serve)
+}
+
+// ServeRPC 默认实例单独监听RPC
+func ServeRPC(config ConfigRPC) {
+	defaultServer.ServeRPC(config)
+}
+
+// NewRPCClient 按config.Network、config.Address连接ServeRPC监听的服务
+func NewRPCClient(config ConfigRPC) (*rpc.Client, error) {
+	return rpc.Dial(config.Network, config.Address)
+}
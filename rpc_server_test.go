@@ -0,0 +1,214 @@
+package gglmm
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type TestRPCService struct{}
+
+func (service *TestRPCService) Actions(cmd string, actions *RPCActionsResponse) error {
+	actions.Actions = append(actions.Actions, NewRPCAction("Double", "int", "*int"))
+	return nil
+}
+
+func (service *TestRPCService) Double(request int, response *int) error {
+	*response = request * 2
+	return nil
+}
+
+func (service *TestRPCService) Panic(request int, response *int) error {
+	panic("panic")
+}
+
+func TestServeRPC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gglmm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := ConfigRPC{
+		Network:  "unix",
+		Address:  filepath.Join(dir, "rpc.sock"),
+		Call:     "TestRPCService.Double",
+		MaxConns: 2,
+	}
+	if !config.Check() {
+		t.Fatal(config)
+	}
+
+	server := NewServer(ServerOptions{})
+	server.RegisterRPC(&TestRPCService{})
+	done := make(chan struct{})
+	go func() {
+		server.ServeRPC(config)
+		close(done)
+	}()
+
+	var client *rpc.Client
+	for i := 0; i < 100; i++ {
+		if client, err = NewRPCClient(config); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	response := 0
+	if err := client.Call(config.Call, 21, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response != 42 {
+		t.Fatal(response)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ServeRPC not return")
+	}
+	if err := client.Call(config.Call, 1, &response); err == nil {
+		t.Fatal("call after shutdown")
+	}
+}
+
+type TestFallbackRPC struct {
+	chanRelease chan struct{}
+}
+
+func (service *TestFallbackRPC) Echo(request string, response *string) error {
+	*response = request
+	return nil
+}
+
+func (service *TestFallbackRPC) Wait(request int, response *int) error {
+	<-service.chanRelease
+	*response = request
+	return nil
+}
+
+func TestRPCFallback(t *testing.T) {
+	if defaultServer.rpcDispatcher.fallback != rpc.DefaultServer {
+		t.Fatal("default fallback")
+	}
+	fallback := rpc.NewServer()
+	fallbackService := &TestFallbackRPC{chanRelease: make(chan struct{})}
+	if err := fallback.Register(fallbackService); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(ServerOptions{RPCServer: fallback})
+	server.RegisterRPC(&TestRPCService{})
+	server.HandleJSONRPC("/jsonrpc")
+	testServer := httptest.NewServer(server.Handler())
+	defer testServer.Close()
+
+	client, err := rpc.DialHTTP("tcp", strings.TrimPrefix(testServer.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	echo := ""
+	if err := client.Call("TestFallbackRPC.Echo", "hello", &echo); err != nil || echo != "hello" {
+		t.Fatal(err, echo)
+	}
+	// fallback中处理中的调用不阻塞同一连接上的其它调用
+	wait := 0
+	waitCall := client.Go("TestFallbackRPC.Wait", 7, &wait, nil)
+	double := 0
+	if err := client.Call("TestRPCService.Double", 21, &double); err != nil || double != 42 {
+		t.Fatal(err, double)
+	}
+	close(fallbackService.chanRelease)
+	if <-waitCall.Done; waitCall.Error != nil || wait != 7 {
+		t.Fatal(waitCall.Error, wait)
+	}
+	if err := client.Call("TestUnknownRPC.Echo", "hello", &echo); err == nil || err.Error() != "rpc: can't find service TestUnknownRPC.Echo" {
+		t.Fatal(err)
+	}
+
+	testResponse := httptest.NewRecorder()
+	testRequest, _ := http.NewRequest("POST", "/jsonrpc", strings.NewReader(`{"jsonrpc":"2.0","method":"TestFallbackRPC.Echo","params":["json"],"id":1}`))
+	server.Handler().ServeHTTP(testResponse, testRequest)
+	response := JSONRPCResponse{}
+	if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != nil || response.Result != "json" {
+		t.Fatal(testResponse.Body.String())
+	}
+}
+
+var (
+	testDefaultRPCOnce        sync.Once
+	testDefaultRPCIntercepted int32
+)
+
+func TestRPCDefaultServer(t *testing.T) {
+	// 默认实例只组装一次，-count大于1时不重复注册
+	testDefaultRPCOnce.Do(func() {
+		RegisterRPCName("TestDefaultRPC", &TestRPCService{}).Interceptor(&RPCInterceptor{
+			Name: "Test",
+			Func: func(call *RPCCall, next RPCInvoker) error {
+				atomic.AddInt32(&testDefaultRPCIntercepted, 1)
+				return next(call)
+			},
+		})
+	})
+	handler := Handler()
+	intercepted := atomic.LoadInt32(&testDefaultRPCIntercepted)
+
+	// 直接使用rpc.DefaultServer、http.DefaultServeMux时仍可调用
+	serverConn, clientConn := net.Pipe()
+	go rpc.DefaultServer.ServeConn(serverConn)
+	client := rpc.NewClient(clientConn)
+	defer client.Close()
+	response := 0
+	if err := client.Call("TestDefaultRPC.Double", 21, &response); err != nil || response != 42 || atomic.LoadInt32(&testDefaultRPCIntercepted) != intercepted {
+		t.Fatal(err, response)
+	}
+	defaultServeMux := httptest.NewServer(http.DefaultServeMux)
+	defer defaultServeMux.Close()
+	testResponse, err := http.Get(defaultServeMux.URL + rpc.DefaultDebugPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(testResponse.Body)
+	testResponse.Body.Close()
+	if !strings.Contains(string(body), "TestDefaultRPC") {
+		t.Fatal(string(body))
+	}
+
+	// Handler中的rpc.DefaultRPCPath经过RPC拦截器
+	testServer := httptest.NewServer(handler)
+	defer testServer.Close()
+	httpClient, err := rpc.DialHTTP("tcp", strings.TrimPrefix(testServer.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpClient.Close()
+	if err := httpClient.Call("TestDefaultRPC.Double", 2, &response); err != nil || response != 4 || atomic.LoadInt32(&testDefaultRPCIntercepted) != intercepted+1 {
+		t.Fatal(err, response)
+	}
+}
@@ -0,0 +1,357 @@
+package gglmm
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+)
+
+// RPCModelRequest Store、Update请求
+// gob调用时Model为模型指针（需gob.Register），JSON-RPC调用时为JSON对象
+type RPCModelRequest struct {
+	ID    uint64      `json:"id"`
+	Model interface{} `json:"model"`
+}
+
+// RPCModelResponse 单个记录响应，Model为模型指针
+type RPCModelResponse struct {
+	Model interface{} `json:"model"`
+}
+
+// RPCListResponse 列表响应，List为模型切片指针
+type RPCListResponse struct {
+	List interface{} `json:"list"`
+}
+
+// RPCPageResponse 分页响应，List为模型切片指针
+type RPCPageResponse struct {
+	List       interface{}       `json:"list"`
+	Pagination Pagination        `json:"pagination"`
+	Cursor     *CursorPagination `json:"cursor,omitempty"`
+}
+
+// RPCFilterFunc RPC过滤函数
+type RPCFilterFunc func([]*Filter, *RPCCall) []*Filter
+
+// RPCBeforeCreateFunc RPC保存前调用
+type RPCBeforeCreateFunc func(interface{}, *RPCCall) (interface{}, error)
+
+// RPCBeforeUpdateFunc RPC更新前调用
+type RPCBeforeUpdateFunc func(interface{}, *RPCCall) (interface{}, error)
+
+// RPCBeforeDeleteFunc RPC删除前调用
+type RPCBeforeDeleteFunc func(interface{}, *RPCCall) (interface{}, error)
+
+// RPCService RPC服务，与HTTPService对应
+// 过滤函数、保存前等函数的参数为本次调用的RPCCall，JSON-RPC调用时RPCCall.Request为HTTP请求
+type RPCService struct {
+	name      string
+	gglmmDB   *DB
+	modelType reflect.Type
+	call      *RPCCall
+
+	maxPageSize       int
+	rejectMaxPageSize bool
+
+	filterFunc       RPCFilterFunc
+	beforeCreateFunc RPCBeforeCreateFunc
+	beforeUpdateFunc RPCBeforeUpdateFunc
+	beforeDeleteFunc RPCBeforeDeleteFunc
+}
+
+// NewRPCService 新建RPC服务，使用默认实例的数据库
+func NewRPCService(model interface{}) *RPCService {
+	return defaultServer.NewRPCService(model)
+}
+
+// NewRPCService 新建RPC服务，使用实例的数据库
+// 名称为 模型名RPCService，模型指针以及模型切片指针注册到gob，客户端需同样注册
+func (server *Server) NewRPCService(model interface{}) *RPCService {
+	modelType := reflect.TypeOf(model)
+	gob.Register(reflect.New(modelType).Interface())
+	gob.Register(reflect.New(reflect.SliceOf(modelType)).Interface())
+	return &RPCService{
+		name:      modelType.Name() + "RPCService",
+		gglmmDB:   server.NewDB(),
+		modelType: modelType,
+	}
+}
+
+// RPCName 注册名称，RegisterRPC时使用
+func (service *RPCService) RPCName() string {
+	return service.name
+}
+
+// SetRPCName 设置注册名称
+func (service *RPCService) SetRPCName(name string) *RPCService {
+	service.name = name
+	return service
+}
+
+// bindRPCCall 返回绑定了本次调用的RPCService
+func (service *RPCService) bindRPCCall(call *RPCCall) interface{} {
+	bound := *service
+	bound.call = call
+	return &bound
+}
+
+// rpcCall 本次调用信息，不经过rpc直接调用方法时只有名称以及参数
+func (service *RPCService) rpcCall(method string, args interface{}) *RPCCall {
+	if service.call != nil {
+		return service.call
+	}
+	return &RPCCall{
+		ServiceMethod: service.name + "." + method,
+		Service:       service.name,
+		Method:        method,
+		Args:          args,
+	}
+}
+
+// db JSON-RPC调用时使用HTTP请求context的DB
+func (service *RPCService) db() *DB {
+	if service.call != nil && service.call.Request != nil {
+		return service.gglmmDB.WithContext(service.call.Request.Context())
+	}
+	return service.gglmmDB
+}
+
+// SetMaxPageSize 设置最大分页大小，0为不限制
+// reject为true时超出返回ErrPageSize，否则按最大分页大小查询
+func (service *RPCService) SetMaxPageSize(maxPageSize int, reject bool) *RPCService {
+	service.maxPageSize = maxPageSize
+	service.rejectMaxPageSize = reject
+	return service
+}
+
+// HandleFilterFunc 设置过滤参数函数
+func (service *RPCService) HandleFilterFunc(handler RPCFilterFunc) *RPCService {
+	service.filterFunc = handler
+	return service
+}
+
+// checkFilterRequest 检查客户端的过滤请求，在filterFunc追加服务端过滤条件前调用
+func (service *RPCService) checkFilterRequest(filterRequest *FilterRequest) error {
+	return service.gglmmDB.ModelFields(reflect.New(service.modelType).Interface()).CheckFilterRequest(filterRequest)
+}
+
+// HandleModelFields 设置可过滤、可排序、可模糊查询列
+func (service *RPCService) HandleModelFields(fields *ModelFields) *RPCService {
+	service.gglmmDB.RegisterModelFields(reflect.New(service.modelType).Interface(), fields)
+	return service
+}
+
+// HandleBeforeCreateFunc 设置保存前执行函数
+func (service *RPCService) HandleBeforeCreateFunc(handler RPCBeforeCreateFunc) *RPCService {
+	service.beforeCreateFunc = handler
+	return service
+}
+
+// HandleBeforeUpdateFunc 设置更新前执行函数
+func (service *RPCService) HandleBeforeUpdateFunc(handler RPCBeforeUpdateFunc) *RPCService {
+	service.beforeUpdateFunc = handler
+	return service
+}
+
+// HandleBeforeDeleteFunc 设置删除前执行函数
+func (service *RPCService) HandleBeforeDeleteFunc(handler RPCBeforeDeleteFunc) *RPCService {
+	service.beforeDeleteFunc = handler
+	return service
+}
+
+// Actions --
+func (service *RPCService) Actions(cmd string, actions *RPCActionsResponse) error {
+	model := "*" + service.modelType.String()
+	actions.Actions = append(actions.Actions, []*RPCAction{
+		NewRPCAction("GetByID", "gglmm.IDRequest", "*gglmm.RPCModelResponse("+model+")"),
+		NewRPCAction("First", "gglmm.FilterRequest", "*gglmm.RPCModelResponse("+model+")"),
+		NewRPCAction("List", "gglmm.FilterRequest", "*gglmm.RPCListResponse(*[]"+service.modelType.String()+")"),
+		NewRPCAction("Page", "gglmm.PageRequest", "*gglmm.RPCPageResponse(*[]"+service.modelType.String()+")"),
+		NewRPCAction("Store", "gglmm.RPCModelRequest("+model+")", "*gglmm.RPCModelResponse("+model+")"),
+		NewRPCAction("Update", "gglmm.RPCModelRequest("+model+")", "*gglmm.RPCModelResponse("+model+")"),
+		NewRPCAction("Remove", "gglmm.IDRequest", "*gglmm.RPCModelResponse("+model+")"),
+		NewRPCAction("Restore", "gglmm.IDRequest", "*gglmm.RPCModelResponse("+model+")"),
+		NewRPCAction("Destroy", "gglmm.IDRequest", "*gglmm.RPCModelResponse("+model+")"),
+	}...)
+	return nil
+}
+
+// model 将请求中的Model转换为模型指针
+func (service *RPCService) model(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, ErrRequest
+	}
+	valueType := reflect.TypeOf(value)
+	if valueType == reflect.PtrTo(service.modelType) {
+		return value, nil
+	}
+	model := reflect.New(service.modelType)
+	if valueType == service.modelType {
+		model.Elem().Set(reflect.ValueOf(value))
+		return model.Interface(), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, ErrModelType
+	}
+	if err := json.Unmarshal(data, model.Interface()); err != nil {
+		return nil, ErrModelType
+	}
+	return model.Interface(), nil
+}
+
+// GetByID 单个
+func (service *RPCService) GetByID(idRequest IDRequest, response *RPCModelResponse) error {
+	model := reflect.New(service.modelType).Interface()
+	if err := service.db().First(model, idRequest); err != nil {
+		return err
+	}
+	response.Model = model
+	return nil
+}
+
+// First 单个
+func (service *RPCService) First(filterRequest FilterRequest, response *RPCModelResponse) error {
+	if err := service.checkFilterRequest(&filterRequest); err != nil {
+		return err
+	}
+	if service.filterFunc != nil {
+		filterRequest.Filters = service.filterFunc(filterRequest.Filters, service.rpcCall("First", filterRequest))
+	}
+	model := reflect.New(service.modelType).Interface()
+	if err := service.db().firstFilter(model, &filterRequest); err != nil {
+		return err
+	}
+	response.Model = model
+	return nil
+}
+
+// List 列表
+func (service *RPCService) List(filterRequest FilterRequest, response *RPCListResponse) error {
+	if err := service.checkFilterRequest(&filterRequest); err != nil {
+		return err
+	}
+	if service.filterFunc != nil {
+		filterRequest.Filters = service.filterFunc(filterRequest.Filters, service.rpcCall("List", filterRequest))
+	}
+	entities := reflect.New(reflect.SliceOf(service.modelType)).Interface()
+	if err := service.db().list(entities, &filterRequest); err != nil {
+		return err
+	}
+	response.List = entities
+	return nil
+}
+
+// Page 分页
+func (service *RPCService) Page(pageRequest PageRequest, response *RPCPageResponse) error {
+	if err := checkPageSize(&pageRequest, service.maxPageSize, service.rejectMaxPageSize); err != nil {
+		return err
+	}
+	if err := service.checkFilterRequest(&pageRequest.FilterRequest); err != nil {
+		return err
+	}
+	if service.filterFunc != nil {
+		pageRequest.Filters = service.filterFunc(pageRequest.Filters, service.rpcCall("Page", pageRequest))
+	}
+	pageResponse := &PageResponse{}
+	pageResponse.List = reflect.New(reflect.SliceOf(service.modelType)).Interface()
+	if err := service.db().page(pageResponse, &pageRequest); err != nil {
+		return err
+	}
+	response.List = pageResponse.List
+	response.Pagination = pageResponse.Pagination
+	response.Cursor = pageResponse.Cursor
+	return nil
+}
+
+// Store 保存
+func (service *RPCService) Store(modelRequest RPCModelRequest, response *RPCModelResponse) error {
+	model, err := service.model(modelRequest.Model)
+	if err != nil {
+		return err
+	}
+	if service.beforeCreateFunc != nil {
+		if model, err = service.beforeCreateFunc(model, service.rpcCall("Store", modelRequest)); err != nil {
+			return err
+		}
+	}
+	if err := service.db().Create(model); err != nil {
+		return err
+	}
+	response.Model = model
+	return nil
+}
+
+// Update 更新整体，ID大于0时作为主键
+func (service *RPCService) Update(modelRequest RPCModelRequest, response *RPCModelResponse) error {
+	model, err := service.model(modelRequest.Model)
+	if err != nil {
+		return err
+	}
+	if modelRequest.ID > 0 {
+		SetPrimaryKeyValue(model, modelRequest.ID)
+	}
+	if service.beforeUpdateFunc != nil {
+		if model, err = service.beforeUpdateFunc(model, service.rpcCall("Update", modelRequest)); err != nil {
+			return err
+		}
+	}
+	if err := service.db().Update(model); err != nil {
+		return err
+	}
+	response.Model = model
+	return nil
+}
+
+// deleteModel 删除前执行函数，返回设置了主键的模型
+func (service *RPCService) deleteModel(idRequest IDRequest, call *RPCCall) (interface{}, error) {
+	model := reflect.New(service.modelType).Interface()
+	if service.beforeDeleteFunc != nil {
+		if err := service.db().First(model, idRequest.ID); err != nil {
+			return nil, err
+		}
+		if _, err := service.beforeDeleteFunc(model, call); err != nil {
+			return nil, err
+		}
+	} else {
+		SetPrimaryKeyValue(model, idRequest.ID)
+	}
+	return model, nil
+}
+
+// Remove 软删除
+func (service *RPCService) Remove(idRequest IDRequest, response *RPCModelResponse) error {
+	model, err := service.deleteModel(idRequest, service.rpcCall("Remove", idRequest))
+	if err != nil {
+		return err
+	}
+	if err := service.db().Remove(model); err != nil {
+		return err
+	}
+	response.Model = model
+	return nil
+}
+
+// Restore 恢复
+func (service *RPCService) Restore(idRequest IDRequest, response *RPCModelResponse) error {
+	model := reflect.New(service.modelType).Interface()
+	SetPrimaryKeyValue(model, idRequest.ID)
+	if err := service.db().Restore(model); err != nil {
+		return err
+	}
+	response.Model = model
+	return nil
+}
+
+// Destroy 直接删除
+func (service *RPCService) Destroy(idRequest IDRequest, response *RPCModelResponse) error {
+	model, err := service.deleteModel(idRequest, service.rpcCall("Destroy", idRequest))
+	if err != nil {
+		return err
+	}
+	if err := service.db().Destroy(model); err != nil {
+		return err
+	}
+	response.Model = model
+	return nil
+}
@@ -0,0 +1,135 @@
+package gglmm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRPCServiceRegister(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	service := &RPCService{
+		name:      "testDefaultModelRPCService",
+		modelType: reflect.TypeOf(testDefaultModel{}),
+	}
+	config := server.RegisterRPC(service)
+	if config.name != "testDefaultModelRPCService" {
+		t.Fatal(config.name)
+	}
+	if _, err := newRPCDispatcher(nil).register(config.name, service, nil); err != nil {
+		t.Fatal(err)
+	}
+	actions := RPCActionsResponse{}
+	if err := service.Actions("all", &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions.Actions) != 9 {
+		t.Fatal(actions.Actions)
+	}
+}
+
+func TestRPCServiceModel(t *testing.T) {
+	service := &RPCService{modelType: reflect.TypeOf(testDefaultModel{})}
+	values := []interface{}{
+		&testDefaultModel{Name: "a"},
+		testDefaultModel{Name: "a"},
+		map[string]interface{}{"name": "a"},
+	}
+	for _, value := range values {
+		model, err := service.model(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if model.(*testDefaultModel).Name != "a" {
+			t.Fatal(model)
+		}
+	}
+	if _, err := service.model(nil); err != ErrRequest {
+		t.Fatal(err)
+	}
+	if _, err := service.model("a"); err != ErrModelType {
+		t.Fatal(err)
+	}
+}
+
+func TestRPCServiceServerFilter(t *testing.T) {
+	db, database := newTestDB(t)
+	service := &RPCService{
+		gglmmDB:   db,
+		modelType: reflect.TypeOf(testDefaultModel{}),
+	}
+	service.HandleModelFields(NewModelFields(testDefaultModel{}).Filters("name"))
+	service.HandleFilterFunc(func(filters []*Filter, call *RPCCall) []*Filter {
+		if call.Method != "List" || call.Request != nil {
+			t.Fatal(call)
+		}
+		return append(filters, NewFilter("status", FilterOperateEqual, "valid"))
+	})
+	filterRequest := FilterRequest{Filters: []*Filter{NewFilter("name", FilterOperateEqual, "a")}}
+	if err := service.List(filterRequest, &RPCListResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if statements := database.Statements(); len(statements) != 1 || !strings.Contains(statements[0], "status = ?") {
+		t.Fatal(statements)
+	}
+	filterRequest = FilterRequest{Filters: []*Filter{NewFilter("status", FilterOperateEqual, "invalid")}}
+	if err := service.List(filterRequest, &RPCListResponse{}); err != ErrFilterField {
+		t.Fatal(err)
+	}
+}
+
+func TestRPCServiceCall(t *testing.T) {
+	db, database := newTestDB(t)
+	service := &RPCService{
+		name:      "testDefaultModelRPCService",
+		gglmmDB:   db,
+		modelType: reflect.TypeOf(testDefaultModel{}),
+	}
+	service.SetMaxPageSize(10, true)
+	service.HandleFilterFunc(func(filters []*Filter, call *RPCCall) []*Filter {
+		if call.ServiceMethod != "testDefaultModelRPCService."+call.Method || call.Request == nil {
+			t.Fatal(call)
+		}
+		return append(filters, NewFilter("status", FilterOperateEqual, call.Request.Header.Get("X-Status")))
+	})
+	service.HandleBeforeCreateFunc(func(model interface{}, call *RPCCall) (interface{}, error) {
+		if call.Method != "Store" || call.Request == nil {
+			t.Fatal(call)
+		}
+		return nil, ErrModelCanNotUpdate
+	})
+	server := NewServer(ServerOptions{})
+	server.RegisterRPC(service)
+	server.HandleJSONRPC("/jsonrpc")
+	handler := server.Handler()
+	call := func(body string) *JSONRPCResponse {
+		testResponse := httptest.NewRecorder()
+		testRequest, _ := http.NewRequest("POST", "/jsonrpc", strings.NewReader(body))
+		testRequest.Header.Set("X-Status", "valid")
+		handler.ServeHTTP(testResponse, testRequest)
+		response := JSONRPCResponse{}
+		if err := json.Unmarshal(testResponse.Body.Bytes(), &response); err != nil {
+			t.Fatal(testResponse.Body.String())
+		}
+		return &response
+	}
+
+	if response := call(`{"jsonrpc":"2.0","method":"testDefaultModelRPCService.List","params":[{}],"id":1}`); response.Error != nil {
+		t.Fatal(response.Error)
+	}
+	if statements := database.Statements(); len(statements) != 1 || !strings.Contains(statements[0], "status = ?") {
+		t.Fatal(statements)
+	}
+	if response := call(`{"jsonrpc":"2.0","method":"testDefaultModelRPCService.Store","params":[{"model":{"name":"a"}}],"id":2}`); response.Error == nil || response.Error.Message != ErrModelCanNotUpdate.Error() {
+		t.Fatal(response)
+	}
+	if response := call(`{"jsonrpc":"2.0","method":"testDefaultModelRPCService.Page","params":[{"pageSize":100}],"id":3}`); response.Error == nil || response.Error.Message != ErrPageSize.Error() {
+		t.Fatal(response)
+	}
+	if statements := database.Statements(); len(statements) != 1 {
+		t.Fatal(statements)
+	}
+}
@@ -0,0 +1,88 @@
+package gglmm
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout 默认优雅关闭等待时间
+const DefaultShutdownTimeout = 30 * time.Second
+
+// SetShutdownTimeout 设置收到信号后优雅关闭的等待时间
+func (server *Server) SetShutdownTimeout(timeout time.Duration) {
+	server.shutdownTimeout = timeout
+}
+
+// OnShutdown 注册关闭时执行的函数，按注册顺序执行，如CloseGormDB
+func (server *Server) OnShutdown(hook func()) {
+	server.shutdownHooks = append(server.shutdownHooks, hook)
+}
+
+// serve 执行listen，收到SIGINT、SIGTERM时优雅关闭
+func (server *Server) serve(listen func() error) {
+	chanErr := make(chan error, 1)
+	go func() {
+		chanErr <- listen()
+	}()
+
+	chanSignal := make(chan os.Signal, 1)
+	signal.Notify(chanSignal, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(chanSignal)
+
+	select {
+	case err := <-chanErr:
+		if err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+		return
+	case sig := <-chanSignal:
+		log.Println("receive signal:", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("shutdown:", err)
+	}
+}
+
+// Shutdown 关闭服务
+// 停止接受新连接，等待处理中的HTTP请求、RPC调用；关闭WebSocket连接，WSHandler收到Over消息，等待messageTransfer结束；
+// 最后执行OnShutdown注册的函数。ctx超时后不再等待，返回ctx.Err()。多次调用只执行一次
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.shutdownOnce.Do(func() {
+		server.shutdownErr = server.shutdown(ctx)
+	})
+	return server.shutdownErr
+}
+
+func (server *Server) shutdown(ctx context.Context) error {
+	server.mutex.Lock()
+	server.closed = true
+	httpServer := server.httpServer
+	rpcListeners := append([]*rpcListener{server.rpcHTTP}, server.rpcListeners...)
+	server.mutex.Unlock()
+	var err error
+	if httpServer != nil {
+		err = httpServer.Shutdown(ctx)
+	}
+	for _, rpcListener := range rpcListeners {
+		if rpcErr := rpcListener.shutdown(ctx); err == nil {
+			err = rpcErr
+		}
+	}
+	server.wsConns.close()
+	if wsErr := server.wsConns.wait(ctx); err == nil {
+		err = wsErr
+	}
+	for _, hook := range server.shutdownHooks {
+		hook()
+	}
+	log.Println("shutdown finish")
+	return err
+}
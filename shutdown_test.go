@@ -0,0 +1,200 @@
+package gglmm
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+func TestShutdownWS(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	var mutex sync.Mutex
+	events := make([]string, 0)
+	record := func(event string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event)
+	}
+	server.HandleWS("/ws/shutdown", func(chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+		for message := range chanRequest {
+			if message.Over {
+				// Over后仍在处理，Shutdown等待处理结束
+				time.Sleep(50 * time.Millisecond)
+				record("over")
+				return
+			}
+			chanResponse <- NewWSMessage(message.Content, false)
+		}
+	})
+	server.OnShutdown(func() {
+		record("hook a")
+	})
+	server.OnShutdown(func() {
+		record("hook b")
+	})
+	address := listenTestServer(t, server)
+	url := "ws://" + address + "/ws/shutdown"
+
+	conn := dialWS(t, url)
+	defer conn.Close()
+	conn.WriteMessage(ws.TextMessage, []byte("ping"))
+	if content := readWS(t, conn); content != "ping" {
+		t.Fatal(content)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	if strings.Join(events, ",") != "over,hook a,hook b" {
+		t.Fatal(events)
+	}
+	mutex.Unlock()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); !ws.IsCloseError(err, ws.CloseGoingAway) {
+		t.Fatal(err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ws.DefaultDialer.Dial(url, nil); err == nil {
+		t.Fatal("dial after shutdown")
+	}
+}
+
+type TestShutdownRPCService struct {
+	chanCall chan int
+}
+
+func (service *TestShutdownRPCService) Actions(cmd string, actions *RPCActionsResponse) error {
+	return nil
+}
+
+func (service *TestShutdownRPCService) Wait(request int, response *int) error {
+	service.chanCall <- request
+	time.Sleep(50 * time.Millisecond)
+	*response = request
+	return nil
+}
+
+// listenTestServer 在空闲端口上ListenAndServe，返回监听地址
+func listenTestServer(t *testing.T, server *Server) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	go server.ListenAndServe(address)
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", address); err == nil {
+			conn.Close()
+			return address
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("listen " + address)
+	return ""
+}
+
+func TestShutdownRPCHTTP(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	service := &TestShutdownRPCService{chanCall: make(chan int, 1)}
+	server.RegisterRPC(service)
+	address := listenTestServer(t, server)
+
+	client, err := rpc.DialHTTP("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	response := 0
+	call := client.Go("TestShutdownRPCService.Wait", 1, &response, nil)
+	<-service.chanCall
+
+	// 等待处理中的调用结束后关闭接管的连接
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-call.Done:
+	case <-time.After(time.Second):
+		t.Fatal("call")
+	}
+	if call.Error != nil || response != 1 {
+		t.Fatal(call.Error, response)
+	}
+	if err := client.Call("TestShutdownRPCService.Wait", 2, &response); err == nil {
+		t.Fatal("call after shutdown")
+	}
+	if _, err := rpc.DialHTTP("tcp", address); err == nil {
+		t.Fatal("dial after shutdown")
+	}
+}
+
+func TestShutdownBeforeListen(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		server.ListenAndServe("127.0.0.1:0")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listen after shutdown")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	chanRelease := make(chan struct{})
+	server.HandleWS("/ws/block", func(chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+		for message := range chanRequest {
+			if message.Over {
+				<-chanRelease
+				return
+			}
+			chanResponse <- NewWSMessage(message.Content, false)
+		}
+	})
+	hooked := false
+	server.OnShutdown(func() {
+		hooked = true
+	})
+	testServer := httptest.NewServer(server.Handler())
+	defer testServer.Close()
+	defer close(chanRelease)
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws/block"
+
+	conn := dialWS(t, url)
+	defer conn.Close()
+	conn.WriteMessage(ws.TextMessage, []byte("ping"))
+	if content := readWS(t, conn); content != "ping" {
+		t.Fatal(content)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	if !hooked {
+		t.Fatal("hook")
+	}
+}
@@ -1,118 +1,470 @@
 package gglmm
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/gorilla/mux"
 	ws "github.com/gorilla/websocket"
 )
 
+// WSMessage 消息类型
+const (
+	WSTextMessage   = ws.TextMessage
+	WSBinaryMessage = ws.BinaryMessage
+)
+
 // WSMessage --
+// Type为WSTextMessage或WSBinaryMessage，发送时为0则使用WSTextMessage
+// Over为true时：接收到的消息中CloseCode、CloseReason为关闭原因；发送时CloseCode不为0则发送关闭帧
 type WSMessage struct {
-	Content []byte
-	Over    bool
+	Type        int
+	Content     []byte
+	Over        bool
+	CloseCode   int
+	CloseReason string
 }
 
 // NewWSMessage --
 func NewWSMessage(content []byte, over bool) *WSMessage {
 	return &WSMessage{
+		Type:    WSTextMessage,
+		Content: content,
+		Over:    over,
+	}
+}
+
+// NewWSBinaryMessage 二进制消息
+func NewWSBinaryMessage(content []byte, over bool) *WSMessage {
+	return &WSMessage{
+		Type:    WSBinaryMessage,
 		Content: content,
 		Over:    over,
 	}
 }
 
+// NewWSCloseMessage 关闭连接并告知客户端原因，code如ws.CloseNormalClosure、ws.ClosePolicyViolation
+func NewWSCloseMessage(code int, reason string) *WSMessage {
+	return &WSMessage{
+		Over:        true,
+		CloseCode:   code,
+		CloseReason: reason,
+	}
+}
+
+// newWSOverMessage 根据读取错误生成Over消息
+func newWSOverMessage(err error) *WSMessage {
+	message := NewWSMessage(nil, true)
+	switch err := err.(type) {
+	case *ws.CloseError:
+		message.CloseCode = err.Code
+		message.CloseReason = err.Text
+	default:
+		if err == ws.ErrReadLimit {
+			message.CloseCode = ws.CloseMessageTooBig
+		} else {
+			message.CloseCode = ws.CloseAbnormalClosure
+		}
+		message.CloseReason = err.Error()
+	}
+	return message
+}
+
 // WSHandler --
 type WSHandler func(chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage)
 
+// WSConnHandler 可访问连接以及WSHub的WSHandler
+type WSConnHandler func(conn *WSConn, chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage)
+
+// WSControlTimeout 未设置写超时时，发送ping、close等控制帧的超时时间
+const WSControlTimeout = 10 * time.Second
+
 // WSHandlerConfig --
 type WSHandlerConfig struct {
-	path      string
-	wsHandler WSHandler
+	path          string
+	wsConnHandler WSConnHandler
+	hub           *WSHub
+	middlewares   []*Middleware
+
+	origins           []string
+	subprotocols      []string
+	enableCompression bool
+	readBufferSize    int
+	writeBufferSize   int
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	readLimit    int64
+	writeTimeout time.Duration
+}
+
+// Middleware 设置升级前执行的中间件，如登录验证；中间件不可包装http.ResponseWriter，否则无法升级
+func (config *WSHandlerConfig) Middleware(middlewares ...*Middleware) *WSHandlerConfig {
+	config.middlewares = middlewares
+	return config
 }
 
-var wsUpgrader *ws.Upgrader = nil
-var wsHandlerConfigs []*WSHandlerConfig = nil
+// Origins 允许的Origin，如https://example.com或example.com，"*"为全部允许
+// 未设置时只允许同源；没有Origin请求头的非浏览器客户端总是允许
+func (config *WSHandlerConfig) Origins(origins ...string) *WSHandlerConfig {
+	config.origins = origins
+	return config
+}
 
-// HandleWS --
-func HandleWS(path string, wsHandler WSHandler) *WSHandlerConfig {
-	if wsUpgrader == nil {
-		wsUpgrader = &ws.Upgrader{}
+// Subprotocols 服务端支持的子协议，按此顺序选择第一个客户端也支持的，结果为WSConn.Subprotocol
+func (config *WSHandlerConfig) Subprotocols(subprotocols ...string) *WSHandlerConfig {
+	config.subprotocols = subprotocols
+	return config
+}
+
+// Compression 是否启用permessage-deflate压缩
+func (config *WSHandlerConfig) Compression(enable bool) *WSHandlerConfig {
+	config.enableCompression = enable
+	return config
+}
+
+// BufferSize 读写缓冲大小，0时使用HTTP服务的缓冲
+func (config *WSHandlerConfig) BufferSize(readBufferSize int, writeBufferSize int) *WSHandlerConfig {
+	config.readBufferSize = readBufferSize
+	config.writeBufferSize = writeBufferSize
+	return config
+}
+
+func (config *WSHandlerConfig) upgrader() *ws.Upgrader {
+	upgrader := &ws.Upgrader{
+		ReadBufferSize:    config.readBufferSize,
+		WriteBufferSize:   config.writeBufferSize,
+		Subprotocols:      config.subprotocols,
+		EnableCompression: config.enableCompression,
 	}
-	if wsHandlerConfigs == nil {
-		wsHandlerConfigs = make([]*WSHandlerConfig, 0)
+	if len(config.origins) > 0 {
+		upgrader.CheckOrigin = config.checkOrigin
 	}
+	return upgrader
+}
+
+func (config *WSHandlerConfig) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range config.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) || strings.EqualFold(allowed, originURL.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Heartbeat 每pingInterval发送ping；pongTimeout内未收到pong或消息时连接超时，WSHandler收到Over消息
+// pongTimeout应大于pingInterval，为0时不检查
+func (config *WSHandlerConfig) Heartbeat(pingInterval time.Duration, pongTimeout time.Duration) *WSHandlerConfig {
+	config.pingInterval = pingInterval
+	config.pongTimeout = pongTimeout
+	return config
+}
+
+// ReadLimit 接收消息的最大字节数，超出时关闭连接，0为不限制
+func (config *WSHandlerConfig) ReadLimit(limit int64) *WSHandlerConfig {
+	config.readLimit = limit
+	return config
+}
+
+// WriteTimeout 发送消息的超时时间，超时时关闭连接，0为不限制
+func (config *WSHandlerConfig) WriteTimeout(timeout time.Duration) *WSHandlerConfig {
+	config.writeTimeout = timeout
+	return config
+}
+
+func (config *WSHandlerConfig) controlDeadline() time.Time {
+	if config.writeTimeout > 0 {
+		return time.Now().Add(config.writeTimeout)
+	}
+	return time.Now().Add(WSControlTimeout)
+}
+
+// extendReadDeadline 收到pong或消息时延长读取期限
+func (config *WSHandlerConfig) extendReadDeadline(conn *ws.Conn) {
+	if config.pongTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(config.pongTimeout))
+	}
+}
+
+// Hub 该路径下连接的WSHub
+func (config *WSHandlerConfig) Hub() *WSHub {
+	return config.hub
+}
+
+// HandleWS --
+func (server *Server) HandleWS(path string, wsHandler WSHandler) *WSHandlerConfig {
+	return server.HandleWSConn(path, func(conn *WSConn, chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+		wsHandler(chanResponse, chanRequest)
+	})
+}
+
+// HandleWSConn 注册WSConnHandler
+func (server *Server) HandleWSConn(path string, wsConnHandler WSConnHandler) *WSHandlerConfig {
 	config := &WSHandlerConfig{
-		path:      path,
-		wsHandler: wsHandler,
+		path:          path,
+		wsConnHandler: wsConnHandler,
+		hub:           NewWSHub(),
 	}
-	wsHandlerConfigs = append(wsHandlerConfigs, config)
+	server.wsHandlerConfigs = append(server.wsHandlerConfigs, config)
 	return config
 }
 
-func messageTransfer(conn *ws.Conn, wsHandler WSHandler) {
+// HandleWS --
+func HandleWS(path string, wsHandler WSHandler) *WSHandlerConfig {
+	return defaultServer.HandleWS(path, wsHandler)
+}
+
+// HandleWSConn 注册WSConnHandler
+func HandleWSConn(path string, wsConnHandler WSConnHandler) *WSHandlerConfig {
+	return defaultServer.HandleWSConn(path, wsConnHandler)
+}
+
+// messageTransfer 在连接与WSHandler之间转发消息，同时发送通过WSHub发送的消息
+// 连接读取结束（客户端关闭、心跳超时、消息过大、发送超时、服务关闭等）时向WSHandler发送Over消息，然后关闭chanRequest
+func (server *Server) messageTransfer(conn *ws.Conn, config *WSHandlerConfig, r *http.Request) {
+	defer server.wsConns.remove(conn)
+
+	if config.readLimit > 0 {
+		conn.SetReadLimit(config.readLimit)
+	}
+	config.extendReadDeadline(conn)
+	conn.SetPongHandler(func(string) error {
+		config.extendReadDeadline(conn)
+		return nil
+	})
+
+	wsConn := newWSConn(config.hub, r)
+	wsConn.Subprotocol = conn.Subprotocol()
+	config.hub.add(wsConn)
+	defer config.hub.remove(wsConn)
+
 	chanRequest := make(chan *WSMessage)
 	chanResponse := make(chan *WSMessage)
-
-	defer func() {
-		log.Println("server messageTransfer close channel")
-		close(chanRequest)
-		close(chanResponse)
-	}()
+	chanHandlerDone := make(chan struct{})
 
 	go func() {
-		wsHandler(chanResponse, chanRequest)
+		defer close(chanHandlerDone)
+		config.wsConnHandler(wsConn, chanResponse, chanRequest)
 		log.Println("server wsHandler finish")
 	}()
 
+	// 服务端主动关闭时记录原因，读取结束后通过Over消息告知WSHandler
+	var closeMutex sync.Mutex
+	var closeMessage *WSMessage
+	closeConn := func(code int, reason string) {
+		closeMutex.Lock()
+		if closeMessage == nil {
+			closeMessage = NewWSCloseMessage(code, reason)
+		}
+		closeMutex.Unlock()
+		conn.Close()
+	}
+
 	go func() {
-		for {
-			message, ok := <-chanResponse
-			if !ok {
-				conn.Close()
+		var chanPing <-chan time.Time
+		if config.pingInterval > 0 {
+			ticker := time.NewTicker(config.pingInterval)
+			defer ticker.Stop()
+			chanPing = ticker.C
+		}
+		over := false
+		write := func(message *WSMessage) {
+			if over || message == nil {
 				return
 			}
 			if message.Content != nil {
-				log.Println("server send message", string(message.Content))
-				conn.WriteMessage(ws.TextMessage, message.Content)
+				log.Println("server send message", len(message.Content))
+				messageType := message.Type
+				if messageType == 0 {
+					messageType = WSTextMessage
+				}
+				if config.writeTimeout > 0 {
+					conn.SetWriteDeadline(time.Now().Add(config.writeTimeout))
+				}
+				if err := conn.WriteMessage(messageType, message.Content); err != nil {
+					log.Println("server write err:", err)
+					over = true
+					closeConn(ws.CloseAbnormalClosure, err.Error())
+					return
+				}
 			}
 			if message.Over {
+				over = true
+				if message.CloseCode != 0 {
+					conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(message.CloseCode, message.CloseReason), config.controlDeadline())
+					closeConn(message.CloseCode, message.CloseReason)
+				} else {
+					closeConn(ws.CloseNormalClosure, "")
+				}
+			}
+		}
+		for {
+			select {
+			case message := <-chanResponse:
+				write(message)
+			case message := <-wsConn.chanSend:
+				write(message)
+			case <-chanPing:
+				if over {
+					continue
+				}
+				if err := conn.WriteControl(ws.PingMessage, nil, config.controlDeadline()); err != nil {
+					log.Println("server ping err:", err)
+					over = true
+					closeConn(ws.CloseAbnormalClosure, err.Error())
+				}
+			case <-chanHandlerDone:
 				conn.Close()
 				return
 			}
 		}
 	}()
 
+	sendRequest := func(message *WSMessage) bool {
+		select {
+		case chanRequest <- message:
+			return true
+		case <-chanHandlerDone:
+			return false
+		}
+	}
 	for {
-		_, content, err := conn.ReadMessage()
+		messageType, content, err := conn.ReadMessage()
 		if err != nil {
 			log.Println("server read err:", err)
+			closeMutex.Lock()
+			message := closeMessage
+			closeMutex.Unlock()
+			if message == nil {
+				message = newWSOverMessage(err)
+			}
+			sendRequest(message)
+			break
+		}
+		log.Println("server receive message", len(content))
+		config.extendReadDeadline(conn)
+		if !sendRequest(&WSMessage{Type: messageType, Content: content}) {
 			break
 		}
-		log.Println("server receive message", string(content))
-		chanRequest <- NewWSMessage(content, false)
 	}
+	close(chanRequest)
+	<-chanHandlerDone
 	log.Println("server messageTransfer finish")
 }
 
-func wsHandler(wsHandler WSHandler) http.HandlerFunc {
+func (server *Server) wsHandler(config *WSHandlerConfig) http.HandlerFunc {
+	upgrader := config.upgrader()
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Println(err)
 			return
 		}
+		if !server.wsConns.add(conn) {
+			conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseGoingAway, ""), time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
 		log.Println("server new conn")
-		go messageTransfer(conn, wsHandler)
+		go server.messageTransfer(conn, config, r)
+	}
+}
+
+// wsConns 服务中的WebSocket连接
+type wsConns struct {
+	mutex     sync.Mutex
+	conns     map[*ws.Conn]bool
+	closed    bool
+	waitGroup sync.WaitGroup
+}
+
+func newWSConns() *wsConns {
+	return &wsConns{
+		conns: make(map[*ws.Conn]bool),
+	}
+}
+
+func (conns *wsConns) add(conn *ws.Conn) bool {
+	conns.mutex.Lock()
+	defer conns.mutex.Unlock()
+	if conns.closed {
+		return false
 	}
+	conns.conns[conn] = true
+	conns.waitGroup.Add(1)
+	return true
 }
 
-func handleWS() {
-	if wsHandlerConfigs == nil || len(wsHandlerConfigs) == 0 {
+func (conns *wsConns) remove(conn *ws.Conn) {
+	conns.mutex.Lock()
+	defer conns.mutex.Unlock()
+	if _, ok := conns.conns[conn]; ok {
+		delete(conns.conns, conn)
+		conns.waitGroup.Done()
+	}
+}
+
+// close 不再接受新连接，向所有连接发送关闭帧并关闭
+// 连接关闭后messageTransfer向WSHandler发送Over消息
+func (conns *wsConns) close() {
+	conns.mutex.Lock()
+	conns.closed = true
+	closeConns := make([]*ws.Conn, 0, len(conns.conns))
+	for conn := range conns.conns {
+		closeConns = append(closeConns, conn)
+	}
+	conns.mutex.Unlock()
+	for _, conn := range closeConns {
+		conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseGoingAway, ""), time.Now().Add(time.Second))
+		conn.Close()
+	}
+}
+
+// wait 等待所有messageTransfer结束
+func (conns *wsConns) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		conns.waitGroup.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleWS 注册到router，与HTTPAction一致使用PanicResponser以及中间件，可使用PathVars
+func (server *Server) handleWS(router *mux.Router) {
+	if len(server.wsHandlerConfigs) == 0 {
 		return
 	}
-	for _, config := range wsHandlerConfigs {
-		path := basePath + config.path
-		http.Handle(path, wsHandler(config.wsHandler))
-		log.Printf("[  ws] %s\n", path)
+	for _, config := range server.wsHandlerConfigs {
+		subrouter := router.PathPrefix(server.basePath).Subrouter()
+		middlewares := make([]string, 0)
+		if server.usePanicResponser {
+			subrouter.Use(mux.MiddlewareFunc(server.middlewarePanicResponser.Func))
+			middlewares = append(middlewares, server.middlewarePanicResponser.Name)
+		}
+		for _, middleware := range config.middlewares {
+			subrouter.Use(mux.MiddlewareFunc(middleware.Func))
+			middlewares = append(middlewares, middleware.Name)
+		}
+		subrouter.Handle(config.path, server.wsHandler(config)).Methods("GET")
+		log.Printf("[  ws] %s [%s]\n", server.basePath+config.path, strings.Join(middlewares, ", "))
 	}
 }
@@ -0,0 +1,147 @@
+package gglmm
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+// Err
+var (
+	ErrWSEventMessage = errors.New("事件消息格式错误")
+	ErrWSEvent        = errors.New("不支持的事件")
+)
+
+// WSEventError 消息格式错误时回复的事件
+const WSEventError = "error"
+
+// WSEvent JSON事件消息：{"event":"...","id":"...","data":{...}}
+// 请求带id时回复同名、同id的事件，data为与HTTP一致的Response
+type WSEvent struct {
+	Event string          `json:"event"`
+	ID    string          `json:"id,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode 解析data
+func (event *WSEvent) Decode(data interface{}) error {
+	if len(event.Data) == 0 {
+		return ErrWSEventMessage
+	}
+	if err := json.Unmarshal(event.Data, data); err != nil {
+		return ErrWSEventMessage
+	}
+	return nil
+}
+
+// NewWSEventMessage 生成事件消息，可通过chanResponse、WSConn.Send、WSHub发送
+func NewWSEventMessage(event string, id string, data interface{}) (*WSMessage, error) {
+	content, err := json.Marshal(struct {
+		Event string      `json:"event"`
+		ID    string      `json:"id,omitempty"`
+		Data  interface{} `json:"data,omitempty"`
+	}{
+		Event: event,
+		ID:    id,
+		Data:  data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewWSMessage(content, false), nil
+}
+
+// WSEventHandler 事件处理函数，返回的Response作为回复，nil时回复OkResponse()；返回错误时回复FailResponse
+type WSEventHandler func(conn *WSConn, event *WSEvent) (*Response, error)
+
+// WSEventRouter 按事件名称分发JSON事件消息
+type WSEventRouter struct {
+	handlers map[string]WSEventHandler
+}
+
+// NewWSEventRouter --
+func NewWSEventRouter() *WSEventRouter {
+	return &WSEventRouter{
+		handlers: make(map[string]WSEventHandler),
+	}
+}
+
+// On 注册事件处理函数
+func (router *WSEventRouter) On(event string, handler WSEventHandler) *WSEventRouter {
+	router.handlers[event] = handler
+	return router
+}
+
+// Handler 转换为WSConnHandler，同一连接的事件按顺序处理
+func (router *WSEventRouter) Handler() WSConnHandler {
+	return func(conn *WSConn, chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+		for message := range chanRequest {
+			if message.Over {
+				return
+			}
+			if response := router.dispatch(conn, message); response != nil {
+				chanResponse <- response
+			}
+		}
+	}
+}
+
+// dispatch 处理一条消息，返回需要回复的消息
+func (router *WSEventRouter) dispatch(conn *WSConn, message *WSMessage) *WSMessage {
+	event := WSEvent{}
+	if err := json.Unmarshal(message.Content, &event); err != nil || event.Event == "" {
+		return router.reply(WSEventError, event.ID, FailResponse(NewErrFileLine(ErrWSEventMessage)))
+	}
+	handler, ok := router.handlers[event.Event]
+	if !ok {
+		if event.ID == "" {
+			return nil
+		}
+		return router.reply(event.Event, event.ID, FailResponse(NewErrFileLine(ErrWSEvent)))
+	}
+	response, err := router.call(handler, conn, &event)
+	if event.ID == "" {
+		if err != nil {
+			log.Printf("ws event %s: %s\n", event.Event, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return router.reply(event.Event, event.ID, FailResponse(NewErrFileLine(err)))
+	}
+	if response == nil {
+		response = OkResponse()
+	}
+	return router.reply(event.Event, event.ID, response)
+}
+
+// call 调用处理函数，panic时返回错误，连接继续
+func (router *WSEventRouter) call(handler WSEventHandler, conn *WSConn, event *WSEvent) (response *Response, err error) {
+	defer func() {
+		if recover := recover(); recover != nil {
+			log.Printf("ws event %s panic: %v\n", event.Event, recover)
+			response = nil
+			err = errors.New("服务忙，请稍后再试")
+		}
+	}()
+	return handler(conn, event)
+}
+
+func (router *WSEventRouter) reply(event string, id string, response *Response) *WSMessage {
+	message, err := NewWSEventMessage(event, id, response)
+	if err != nil {
+		log.Println("ws event reply:", err)
+		message, _ = NewWSEventMessage(event, id, FailResponse(NewErrFileLine(err)))
+	}
+	return message
+}
+
+// HandleWSEvent 使用WSEventRouter处理JSON事件消息
+func (server *Server) HandleWSEvent(path string, router *WSEventRouter) *WSHandlerConfig {
+	return server.HandleWSConn(path, router.Handler())
+}
+
+// HandleWSEvent 使用WSEventRouter处理JSON事件消息
+func HandleWSEvent(path string, router *WSEventRouter) *WSHandlerConfig {
+	return defaultServer.HandleWSEvent(path, router)
+}
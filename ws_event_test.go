@@ -0,0 +1,66 @@
+package gglmm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestWSEventRouter(t *testing.T) {
+	router := NewWSEventRouter().
+		On("echo", func(conn *WSConn, event *WSEvent) (*Response, error) {
+			data := make(map[string]interface{})
+			if err := event.Decode(&data); err != nil {
+				return nil, err
+			}
+			return OkResponse().AddData("echo", data["text"]), nil
+		}).
+		On("fail", func(conn *WSConn, event *WSEvent) (*Response, error) {
+			return nil, errors.New("fail")
+		}).
+		On("panic", func(conn *WSConn, event *WSEvent) (*Response, error) {
+			panic("panic")
+		})
+	conn := &WSConn{ID: "1"}
+
+	dispatch := func(content string) (*WSEvent, *Response) {
+		message := router.dispatch(conn, NewWSMessage([]byte(content), false))
+		if message == nil {
+			return nil, nil
+		}
+		event := WSEvent{}
+		if err := json.Unmarshal(message.Content, &event); err != nil {
+			t.Fatal(err)
+		}
+		response := Response{}
+		if err := event.Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return &event, &response
+	}
+
+	event, response := dispatch(`{"event":"echo","id":"1","data":{"text":"hello"}}`)
+	if event.Event != "echo" || event.ID != "1" || response.StatusCode != http.StatusOK || response.Data["echo"] != "hello" {
+		t.Fatal(event, response)
+	}
+	event, response = dispatch(`{"event":"fail","id":"2"}`)
+	if event.ID != "2" || response.ErrorCode != ResponseFailCode || response.ErrorMessage != "fail" {
+		t.Fatal(event, response)
+	}
+	event, response = dispatch(`{"event":"panic","id":"3"}`)
+	if event.ID != "3" || response.ErrorCode != ResponseFailCode {
+		t.Fatal(event, response)
+	}
+	event, response = dispatch(`{"event":"unknown","id":"4"}`)
+	if event.ID != "4" || response.ErrorMessage != ErrWSEvent.Error() {
+		t.Fatal(event, response)
+	}
+	event, response = dispatch(`not json`)
+	if event.Event != WSEventError || response.ErrorMessage != ErrWSEventMessage.Error() {
+		t.Fatal(event, response)
+	}
+	if event, _ := dispatch(`{"event":"fail"}`); event != nil {
+		t.Fatal(event)
+	}
+}
@@ -0,0 +1,223 @@
+package gglmm
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// WSSendBufferSize 每个连接通过WSHub发送消息的缓冲大小，缓冲满时发送失败
+const WSSendBufferSize = 256
+
+var wsConnID uint64
+
+// WSConn WebSocket连接
+// Request为升级请求，可获取请求头、PathVars以及中间件写入context的登录信息；升级后Request.Context()已结束
+type WSConn struct {
+	ID          string
+	Hub         *WSHub
+	Request     *http.Request
+	Subprotocol string // 协商的子协议
+
+	chanSend chan *WSMessage
+}
+
+func newWSConn(hub *WSHub, r *http.Request) *WSConn {
+	return &WSConn{
+		ID:       strconv.FormatUint(atomic.AddUint64(&wsConnID, 1), 10),
+		Hub:      hub,
+		Request:  r,
+		chanSend: make(chan *WSMessage, WSSendBufferSize),
+	}
+}
+
+// Send 发送消息，不阻塞，缓冲满时返回false
+func (conn *WSConn) Send(message *WSMessage) bool {
+	select {
+	case conn.chanSend <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// Join 加入房间
+func (conn *WSConn) Join(room string) {
+	conn.Hub.Join(conn.ID, room)
+}
+
+// Leave 离开房间
+func (conn *WSConn) Leave(room string) {
+	conn.Hub.Leave(conn.ID, room)
+}
+
+// Rooms 所在房间
+func (conn *WSConn) Rooms() []string {
+	return conn.Hub.ConnRooms(conn.ID)
+}
+
+// WSHub 同一WSHandlerConfig下的连接以及房间
+type WSHub struct {
+	mutex     sync.RWMutex
+	conns     map[string]*WSConn
+	rooms     map[string]map[string]*WSConn
+	connRooms map[string]map[string]bool
+}
+
+// NewWSHub --
+func NewWSHub() *WSHub {
+	return &WSHub{
+		conns:     make(map[string]*WSConn),
+		rooms:     make(map[string]map[string]*WSConn),
+		connRooms: make(map[string]map[string]bool),
+	}
+}
+
+func (hub *WSHub) add(conn *WSConn) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.conns[conn.ID] = conn
+	hub.connRooms[conn.ID] = make(map[string]bool)
+}
+
+// remove 移除连接并离开所有房间，messageTransfer结束时调用
+func (hub *WSHub) remove(conn *WSConn) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	for room := range hub.connRooms[conn.ID] {
+		hub.leave(conn.ID, room)
+	}
+	delete(hub.connRooms, conn.ID)
+	delete(hub.conns, conn.ID)
+}
+
+// Conn 按ID获取连接
+func (hub *WSHub) Conn(id string) (*WSConn, bool) {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	conn, ok := hub.conns[id]
+	return conn, ok
+}
+
+// Count 连接数
+func (hub *WSHub) Count() int {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	return len(hub.conns)
+}
+
+// Join 连接加入房间，连接不存在时返回false
+func (hub *WSHub) Join(id string, room string) bool {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	conn, ok := hub.conns[id]
+	if !ok {
+		return false
+	}
+	if _, ok := hub.rooms[room]; !ok {
+		hub.rooms[room] = make(map[string]*WSConn)
+	}
+	hub.rooms[room][id] = conn
+	hub.connRooms[id][room] = true
+	return true
+}
+
+// Leave 连接离开房间
+func (hub *WSHub) Leave(id string, room string) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.leave(id, room)
+}
+
+func (hub *WSHub) leave(id string, room string) {
+	if conns, ok := hub.rooms[room]; ok {
+		delete(conns, id)
+		if len(conns) == 0 {
+			delete(hub.rooms, room)
+		}
+	}
+	if rooms, ok := hub.connRooms[id]; ok {
+		delete(rooms, room)
+	}
+}
+
+// Rooms 所有房间
+func (hub *WSHub) Rooms() []string {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	rooms := make([]string, 0, len(hub.rooms))
+	for room := range hub.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// ConnRooms 连接所在房间
+func (hub *WSHub) ConnRooms(id string) []string {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	rooms := make([]string, 0, len(hub.connRooms[id]))
+	for room := range hub.connRooms[id] {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// RoomConns 房间内的连接ID
+func (hub *WSHub) RoomConns(room string) []string {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	ids := make([]string, 0, len(hub.rooms[room]))
+	for id := range hub.rooms[room] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// SendTo 向连接发送消息，连接不存在或缓冲满时返回false
+func (hub *WSHub) SendTo(id string, message *WSMessage) bool {
+	conn, ok := hub.Conn(id)
+	if !ok {
+		return false
+	}
+	return conn.Send(message)
+}
+
+// Broadcast 向所有连接发送消息，返回发送成功的连接数
+func (hub *WSHub) Broadcast(message *WSMessage) int {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	count := 0
+	for _, conn := range hub.conns {
+		if conn.Send(message) {
+			count++
+		}
+	}
+	return count
+}
+
+// BroadcastRoom 向房间内的连接发送消息，except为不发送的连接ID，返回发送成功的连接数
+func (hub *WSHub) BroadcastRoom(room string, message *WSMessage, except ...string) int {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	count := 0
+	for id, conn := range hub.rooms[room] {
+		if containsString(except, id) {
+			continue
+		}
+		if conn.Send(message) {
+			count++
+		}
+	}
+	return count
+}
+
+func containsString(items []string, item string) bool {
+	for _, value := range items {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
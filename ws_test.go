@@ -0,0 +1,271 @@
+package gglmm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+func roomWSHandler(conn *WSConn, chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+	for message := range chanRequest {
+		if message.Over {
+			return
+		}
+		if string(message.Content) == "join" {
+			conn.Join("room")
+			chanResponse <- NewWSMessage([]byte("joined"), false)
+			continue
+		}
+		conn.Hub.BroadcastRoom("room", NewWSMessage(message.Content, false), conn.ID)
+	}
+}
+
+func dialWS(t *testing.T, url string) *ws.Conn {
+	conn, _, err := ws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readWS(t *testing.T, conn *ws.Conn) string {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, content, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestWSHub(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	config := server.HandleWSConn("/ws/room", roomWSHandler)
+	testServer := httptest.NewServer(server.Handler())
+	defer testServer.Close()
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws/room"
+
+	connA := dialWS(t, url)
+	defer connA.Close()
+	connB := dialWS(t, url)
+	for _, conn := range []*ws.Conn{connA, connB} {
+		conn.WriteMessage(ws.TextMessage, []byte("join"))
+		if content := readWS(t, conn); content != "joined" {
+			t.Fatal(content)
+		}
+	}
+	if config.Hub().Count() != 2 || len(config.Hub().RoomConns("room")) != 2 {
+		t.Fatal(config.Hub().RoomConns("room"))
+	}
+
+	connA.WriteMessage(ws.TextMessage, []byte("hello"))
+	if content := readWS(t, connB); content != "hello" {
+		t.Fatal(content)
+	}
+	if config.Hub().Broadcast(NewWSMessage([]byte("all"), false)) != 2 {
+		t.Fatal("broadcast")
+	}
+	if content := readWS(t, connA); content != "all" {
+		t.Fatal(content)
+	}
+
+	connB.Close()
+	for i := 0; i < 100 && config.Hub().Count() != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if config.Hub().Count() != 1 || len(config.Hub().RoomConns("room")) != 1 {
+		t.Fatal(config.Hub().Count())
+	}
+}
+
+func TestWSHeartbeatReadLimit(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	chanOver := make(chan bool, 2)
+	server.HandleWS("/ws/limit", func(chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+		for message := range chanRequest {
+			if message.Over {
+				chanOver <- true
+				return
+			}
+		}
+	}).Heartbeat(20*time.Millisecond, 100*time.Millisecond).ReadLimit(8).WriteTimeout(time.Second)
+	testServer := httptest.NewServer(server.Handler())
+	defer testServer.Close()
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws/limit"
+
+	// 不读取，不回复pong
+	silentConn := dialWS(t, url)
+	defer silentConn.Close()
+	select {
+	case <-chanOver:
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat timeout")
+	}
+
+	conn := dialWS(t, url)
+	defer conn.Close()
+	conn.WriteMessage(ws.TextMessage, []byte("0123456789"))
+	select {
+	case <-chanOver:
+	case <-time.After(time.Second):
+		t.Fatal("read limit")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); !ws.IsCloseError(err, ws.CloseMessageTooBig) {
+		t.Fatal(err)
+	}
+}
+
+func TestWSMessageType(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	chanOver := make(chan *WSMessage, 1)
+	server.HandleWS("/ws/echo", func(chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+		for message := range chanRequest {
+			if message.Over {
+				chanOver <- message
+				return
+			}
+			if string(message.Content) == "bye" {
+				chanResponse <- NewWSCloseMessage(4000, "bye")
+				continue
+			}
+			chanResponse <- &WSMessage{Type: message.Type, Content: message.Content}
+		}
+	})
+	testServer := httptest.NewServer(server.Handler())
+	defer testServer.Close()
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws/echo"
+
+	conn := dialWS(t, url)
+	defer conn.Close()
+	conn.WriteMessage(ws.BinaryMessage, []byte{0, 1, 2})
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	messageType, content, err := conn.ReadMessage()
+	if err != nil || messageType != ws.BinaryMessage || len(content) != 3 {
+		t.Fatal(messageType, content, err)
+	}
+	conn.WriteMessage(ws.TextMessage, []byte("bye"))
+	_, _, err = conn.ReadMessage()
+	if closeErr, ok := err.(*ws.CloseError); !ok || closeErr.Code != 4000 || closeErr.Text != "bye" {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-chanOver:
+		if message.CloseCode != 4000 {
+			t.Fatal(message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("over")
+	}
+
+	conn = dialWS(t, url)
+	conn.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, "done"))
+	select {
+	case message := <-chanOver:
+		if message.CloseCode != ws.CloseNormalClosure || message.CloseReason != "done" {
+			t.Fatal(message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("over")
+	}
+	conn.Close()
+}
+
+func TestWSMiddleware(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	auth := &Middleware{
+		Name: "Auth",
+		Func: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "token" {
+					UnauthorizedResponse().JSON(w)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		},
+	}
+	server.HandleWSConn("/ws/room/{room}", func(conn *WSConn, chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+		room, _ := PathVar(conn.Request, "room")
+		chanResponse <- NewWSMessage([]byte(room+":"+conn.Request.Header.Get("Authorization")), false)
+		for message := range chanRequest {
+			if message.Over {
+				return
+			}
+		}
+	}).Middleware(auth)
+	testServer := httptest.NewServer(server.Handler())
+	defer testServer.Close()
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/ws/room/a"
+
+	if _, response, err := ws.DefaultDialer.Dial(url, nil); err == nil || response.StatusCode != http.StatusUnauthorized {
+		t.Fatal(err)
+	}
+	conn, _, err := ws.DefaultDialer.Dial(url, http.Header{"Authorization": []string{"token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if content := readWS(t, conn); content != "a:token" {
+		t.Fatal(content)
+	}
+}
+
+func TestWSUpgraderOptions(t *testing.T) {
+	server := NewServer(ServerOptions{})
+	handler := func(conn *WSConn, chanResponse chan<- *WSMessage, chanRequest <-chan *WSMessage) {
+		chanResponse <- NewWSMessage([]byte(conn.Subprotocol), false)
+		for message := range chanRequest {
+			if message.Over {
+				return
+			}
+		}
+	}
+	server.HandleWSConn("/ws/default", handler)
+	server.HandleWSConn("/ws/options", handler).
+		Origins("https://example.com").
+		Subprotocols("v2", "v1").
+		Compression(true).
+		BufferSize(1024, 1024)
+	testServer := httptest.NewServer(server.Handler())
+	defer testServer.Close()
+	url := "ws" + strings.TrimPrefix(testServer.URL, "http")
+
+	cases := []struct {
+		path    string
+		origin  string
+		upgrade bool
+	}{
+		{"/ws/default", "", true},
+		{"/ws/default", testServer.URL, true},
+		{"/ws/default", "https://example.com", false},
+		{"/ws/options", "https://example.com", true},
+		{"/ws/options", "https://other.com", false},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		if c.origin != "" {
+			header.Set("Origin", c.origin)
+		}
+		conn, _, err := ws.DefaultDialer.Dial(url+c.path, header)
+		if (err == nil) != c.upgrade {
+			t.Fatal(c, err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+
+	dialer := &ws.Dialer{Subprotocols: []string{"v1", "v2"}, EnableCompression: true}
+	conn, _, err := dialer.Dial(url+"/ws/options", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if content := readWS(t, conn); content != "v2" || conn.Subprotocol() != "v2" {
+		t.Fatal(content)
+	}
+}